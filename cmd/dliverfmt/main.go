@@ -0,0 +1,329 @@
+// Command dliverfmt pretty prints dliver (see zaplog.NewEncoder) or zap JSON log lines in the dliver-dev format.
+//
+// Usage:
+//
+//	kubectl logs my-pod | dliverfmt -level warn -field correlation_id=abc
+//	dliverfmt -f -grep timeout service.log
+//	dliverfmt -format msgpack service.msgpack
+//
+// Lines which can't be parsed are printed as they are, unless level or field filters are used.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/proemergotech/log/v3/dliverlog"
+	"github.com/proemergotech/log/v3/zaplog"
+)
+
+const followInterval = 250 * time.Millisecond
+
+type fieldFilters map[string]string
+
+type printer struct {
+	encoder  zapcore.Encoder
+	msgpack  bool
+	options  []dliverlog.ParseOption
+	out      io.Writer
+	minLevel zapcore.Level
+	grep     *regexp.Regexp
+	fields   fieldFilters
+}
+
+type followReader struct {
+	r io.Reader
+}
+
+func main() {
+	if err := run(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "dliverfmt:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	level := flag.String("level", "debug", "minimum level of the printed entries")
+	grep := flag.String("grep", "", "print only the lines matching the regular expression")
+	follow := flag.Bool("f", false, "keep reading the last file when its end is reached, like tail -f")
+	indent := flag.Bool("indent", true, "indent the fields")
+	format := flag.String("format", "text", "input format: text (dliver or zap JSON lines) or msgpack (see zaplog.NewMsgpackEncoder)")
+	loggerNames := flag.Bool("logger-names", true, "recognise the logger names of the v1 dliver lines, disable it for loggers without names")
+	fields := make(fieldFilters)
+	flag.Var(fields, "field", "print only the entries having a field with the given value, format: key=value, can be repeated")
+	flag.Parse()
+
+	p := &printer{out: os.Stdout, fields: fields, options: []dliverlog.ParseOption{dliverlog.LoggerNames(*loggerNames)}}
+	switch *format {
+	case "text":
+	case "msgpack":
+		p.msgpack = true
+	default:
+		return fmt.Errorf("invalid format %q, expected text or msgpack", *format)
+	}
+	if err := p.minLevel.UnmarshalText([]byte(*level)); err != nil {
+		return err
+	}
+	if *grep != "" {
+		re, err := regexp.Compile(*grep)
+		if err != nil {
+			return err
+		}
+		p.grep = re
+	}
+
+	enc, err := zaplog.NewDevelopmentEncoder(zaplog.IndentFields(*indent))(zapcore.EncoderConfig{})
+	if err != nil {
+		return err
+	}
+	p.encoder = enc
+
+	files := flag.Args()
+	if len(files) == 0 {
+		return p.print(os.Stdin)
+	}
+
+	for i, name := range files {
+		f, err := os.Open(name) // #nosec
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader = f
+		if *follow && i == len(files)-1 {
+			r = &followReader{r: f}
+		}
+
+		err = p.print(r)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *printer) print(r io.Reader) error {
+	s := dliverlog.NewScanner(r, p.options...)
+	if p.msgpack {
+		s = dliverlog.NewMsgpackScanner(r)
+	}
+
+	for s.Scan() {
+		e := s.Entry()
+		if s.ParseErr() != nil {
+			var ok bool
+			if e, ok = parseJSON(e.Raw); !ok {
+				// the lines can't be filtered by level or fields
+				if p.minLevel == zapcore.DebugLevel && len(p.fields) == 0 && (p.grep == nil || p.grep.MatchString(e.Raw)) {
+					_, _ = fmt.Fprintln(p.out, e.Raw)
+				}
+				continue
+			}
+		}
+
+		if !p.match(e) {
+			continue
+		}
+
+		if err := p.printEntry(e); err != nil {
+			return err
+		}
+	}
+
+	return s.Err()
+}
+
+func (p *printer) match(e *dliverlog.Entry) bool {
+	if level(e.Level) < p.minLevel {
+		return false
+	}
+	if p.grep != nil && !p.grep.MatchString(e.Raw) {
+		return false
+	}
+
+	for k, v := range p.fields {
+		if special, ok := e.Special[k]; ok && special == v {
+			continue
+		}
+		if field, ok := e.Fields[k]; ok && fmt.Sprint(field) == v {
+			continue
+		}
+		return false
+	}
+
+	return true
+}
+
+func (p *printer) printEntry(e *dliverlog.Entry) error {
+	fields := make([]zapcore.Field, 0, len(e.Special)+len(e.Fields))
+	for k, v := range e.Special {
+		fields = append(fields, zap.String(k, v))
+	}
+
+	var stack, verbose string
+	for k, v := range e.Fields {
+		switch {
+		case k == zaplog.StacktraceKey:
+			stack = formatStack(v)
+		case strings.HasSuffix(k, "Verbose"):
+			// the verbose error format of zap contains the stack trace of the pkg/errors errors
+			verbose += fmt.Sprint(v) + "\n"
+		default:
+			fields = append(fields, zap.Reflect(k, v))
+		}
+	}
+	if verbose != "" {
+		stack = verbose
+	}
+
+	buf, err := p.encoder.EncodeEntry(zapcore.Entry{
+		Level:      level(e.Level),
+		Time:       e.Time,
+		LoggerName: e.LoggerName,
+		Message:    e.Message,
+	}, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	if _, err := p.out.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	_, err = io.WriteString(p.out, stack)
+
+	return err
+}
+
+// formatStack formats the structured stack traces of zaplog.Encoder or the stack traces of zap.
+func formatStack(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s + "\n"
+	}
+
+	frames, ok := v.([]interface{})
+	if !ok {
+		return fmt.Sprint(v) + "\n"
+	}
+
+	b := new(strings.Builder)
+	b.WriteString("Stack trace:\n")
+	for _, f := range frames {
+		frame, ok := f.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		_, _ = fmt.Fprintf(b, "%v\n\t%v:%v\n", frame["function"], frame["file"], frame["line"])
+	}
+
+	return b.String()
+}
+
+// parseJSON parses the log lines of the zap JSON encoder.
+func parseJSON(line string) (*dliverlog.Entry, bool) {
+	e := &dliverlog.Entry{Raw: line}
+	if !strings.HasPrefix(strings.TrimSpace(line), "{") {
+		return e, false
+	}
+
+	d := json.NewDecoder(bytes.NewReader([]byte(line)))
+	d.UseNumber()
+	if err := d.Decode(&e.Fields); err != nil {
+		return e, false
+	}
+
+	e.Level = stringField(e.Fields, "level", "severity", "log.level")
+	e.Message = stringField(e.Fields, "msg", "message", "short_message")
+	e.LoggerName = stringField(e.Fields, "logger", "name", "log.logger")
+
+	for _, key := range []string{"ts", "time", "timestamp", "@timestamp"} {
+		v, ok := e.Fields[key]
+		if !ok {
+			continue
+		}
+		delete(e.Fields, key)
+
+		switch t := v.(type) {
+		case json.Number:
+			if f, err := t.Float64(); err == nil {
+				sec := int64(f)
+				e.Time = time.Unix(sec, int64((f-float64(sec))*float64(time.Second)))
+			}
+		case string:
+			e.Time, _ = time.Parse(time.RFC3339Nano, t)
+		}
+		break
+	}
+
+	return e, true
+}
+
+func stringField(fields map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if v, ok := fields[key].(string); ok {
+			delete(fields, key)
+			return v
+		}
+	}
+
+	return ""
+}
+
+func level(name string) zapcore.Level {
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(strings.ToLower(name))); err != nil {
+		switch strings.ToLower(name) {
+		case "warning":
+			return zapcore.WarnLevel
+		case "critical":
+			return zapcore.PanicLevel
+		}
+		return zapcore.ErrorLevel
+	}
+
+	return l
+}
+
+func (f fieldFilters) String() string {
+	filters := make([]string, 0, len(f))
+	for k, v := range f {
+		filters = append(filters, k+"="+v)
+	}
+	sort.Strings(filters)
+
+	return strings.Join(filters, ",")
+}
+
+func (f fieldFilters) Set(value string) error {
+	sep := strings.IndexByte(value, '=')
+	if sep <= 0 {
+		return fmt.Errorf("invalid field filter %q, expected key=value", value)
+	}
+	f[value[:sep]] = value[sep+1:]
+
+	return nil
+}
+
+// Read blocks at the end of the file until new data is written to it.
+func (fr *followReader) Read(b []byte) (int, error) {
+	for {
+		n, err := fr.r.Read(b)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		time.Sleep(followInterval)
+	}
+}
@@ -0,0 +1,95 @@
+package dliverlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/proemergotech/log/v3/internal/msgpack"
+)
+
+// NewMsgpackScanner creates a Scanner reading the MessagePack frames written by the zaplog MessagePack encoder
+// (see zaplog.NewMsgpackEncoder). The Raw field of the entries contains the JSON representation of the frames.
+// Frames which are valid MessagePack values, but not log entries, are reported by ParseErr,
+// invalid MessagePack data stops the scanning and is reported by Err.
+func NewMsgpackScanner(r io.Reader) *Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	s.Split(splitMsgpack)
+
+	return &Scanner{scanner: s, parse: ParseMsgpack}
+}
+
+// ParseMsgpack parses a MessagePack frame written by the zaplog MessagePack encoder.
+func ParseMsgpack(frame []byte) (*Entry, error) {
+	v, _, err := msgpack.Decode(frame)
+	if err != nil {
+		return &Entry{Raw: string(frame)}, &ParseError{Line: string(frame), Reason: "invalid frame"}
+	}
+
+	e := &Entry{Raw: rawJSON(v)}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return e, &ParseError{Line: e.Raw, Reason: "invalid frame"}
+	}
+
+	if e.Time, ok = m["ts"].(time.Time); !ok {
+		return e, &ParseError{Line: e.Raw, Reason: "invalid time"}
+	}
+	if e.Level, ok = m["level"].(string); !ok {
+		return e, &ParseError{Line: e.Raw, Reason: "missing level"}
+	}
+	if logger, ok := m["logger"].(string); ok {
+		e.LoggerName = logger
+	}
+	if e.Message, ok = m["msg"].(string); !ok {
+		return e, &ParseError{Line: e.Raw, Reason: "missing message"}
+	}
+
+	special, ok := m["special"].(map[string]interface{})
+	if !ok {
+		return e, &ParseError{Line: e.Raw, Reason: "missing special keys"}
+	}
+	e.Special = make(map[string]string, len(special))
+	for k, v := range special {
+		e.Special[k] = fmt.Sprint(v)
+	}
+
+	if e.Fields, ok = m["fields"].(map[string]interface{}); !ok {
+		return e, &ParseError{Line: e.Raw, Reason: "missing fields"}
+	}
+
+	return e, nil
+}
+
+func splitMsgpack(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	n, err := msgpack.Skip(data)
+	switch {
+	case err == msgpack.ErrShortBuffer && !atEOF:
+		// request more data
+		return 0, nil, nil
+	case err == msgpack.ErrShortBuffer:
+		return 0, nil, errors.New("dliverlog: truncated msgpack frame")
+	case err != nil:
+		return 0, nil, errors.Wrap(err, "dliverlog: invalid msgpack data")
+	}
+
+	return n, data[:n], nil
+}
+
+func rawJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
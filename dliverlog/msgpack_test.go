@@ -0,0 +1,83 @@
+package dliverlog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/proemergotech/log/v3/zaplog"
+)
+
+func TestMsgpackScannerRoundTrip(t *testing.T) {
+	enc, err := zaplog.NewMsgpackEncoder([]string{"correlation_id"})(zap.NewProductionEncoderConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entryTime := time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	var data bytes.Buffer
+	for _, entry := range []zapcore.Entry{
+		{Level: zapcore.InfoLevel, Time: entryTime, LoggerName: "db", Message: "first"},
+		{Level: zapcore.WarnLevel, Time: entryTime, Message: "second"},
+	} {
+		buf, err := enc.EncodeEntry(entry, []zapcore.Field{
+			zap.String("correlation_id", "abc"),
+			zap.Int("port", 5432),
+			zap.Strings("tags", []string{"x", "y"}),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = data.Write(buf.Bytes())
+		buf.Free()
+	}
+
+	// the frames are split across the reads
+	s := NewMsgpackScanner(iotest.OneByteReader(&data))
+
+	var entries []*Entry
+	for s.Scan() {
+		if err := s.ParseErr(); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, s.Entry())
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %v", len(entries))
+	}
+	for i, want := range []struct{ level, logger, message string }{{"info", "db", "first"}, {"warn", "", "second"}} {
+		e := entries[i]
+		if e.Level != want.level || e.LoggerName != want.logger || e.Message != want.message {
+			t.Errorf("expected %v, got %v %v %v", want, e.Level, e.LoggerName, e.Message)
+		}
+		if !e.Time.Equal(entryTime) {
+			t.Errorf("expected time %v, got %v", entryTime, e.Time)
+		}
+		if !reflect.DeepEqual(e.Special, map[string]string{"correlation_id": "abc"}) {
+			t.Errorf("unexpected special keys %v", e.Special)
+		}
+		wantFields := map[string]interface{}{"port": uint64(5432), "tags": []interface{}{"x", "y"}}
+		if !reflect.DeepEqual(e.Fields, wantFields) {
+			t.Errorf("expected fields %#v, got %#v", wantFields, e.Fields)
+		}
+	}
+}
+
+func TestMsgpackScannerTruncated(t *testing.T) {
+	// a map header announcing one pair without the data
+	s := NewMsgpackScanner(bytes.NewReader([]byte{0x81, 0xa1, 'a'}))
+	for s.Scan() {
+	}
+	if s.Err() == nil {
+		t.Errorf("expected an error for the truncated frame")
+	}
+}
@@ -0,0 +1,312 @@
+// Package dliverlog parses the log lines written by zaplog.Encoder:
+//
+//	<RFC3339Nano or epoch time> <level> [<logger name> - ]<message> ##<key=value;...>##{json fields}
+//	<RFC3339Nano or epoch time> <level> [<escaped logger name> ]- <message> ##v2<escaped key=escaped value;...>##{json fields}
+//
+// and the MessagePack frames written by the zaplog MessagePack encoder, see NewMsgpackScanner.
+package dliverlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/proemergotech/log/v3/zaplog"
+)
+
+const maxLineSize = 16 * 1024 * 1024
+
+var msgUnescaper = strings.NewReplacer("\\n", "\n", "\\r", "\r")
+
+// Entry is a parsed log line.
+type Entry struct {
+	Time       time.Time
+	Level      string
+	LoggerName string
+	Message    string
+	// Special contains the special keys and values, see zaplog.NewEncoder.
+	Special map[string]string
+	Fields  map[string]interface{}
+	// Version is the version of the dliver format, see zaplog.FormatVersion.
+	Version int
+	Raw     string
+}
+
+type ParseOption func(po *ParseOptions)
+
+type ParseOptions struct {
+	loggerNames bool
+}
+
+// ParseError is returned for malformed log lines.
+type ParseError struct {
+	Line   string
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return "dliverlog: " + e.Reason + ": " + e.Line
+}
+
+// Parse parses a log line.
+// The time must be in the RFC 3339 or in one of the epoch formats, see zaplog.TimeFormat.
+// The special values may not contain ' ##<'.
+// The logger name of the v1 format is ambiguous: it's recognised only if it doesn't contain spaces, and a message like
+// "failed - retrying" of a logger without name is parsed as the "retrying" message of the "failed" logger,
+// see LoggerNames. The logger section of the v2 format is unambiguous, see zaplog.FormatV2.
+func Parse(line string, options ...ParseOption) (*Entry, error) {
+	po := ParseOptions{loggerNames: true}
+	for _, option := range options {
+		option(&po)
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	e := &Entry{Raw: line}
+
+	sep := strings.IndexByte(line, ' ')
+	if sep < 0 {
+		return e, &ParseError{Line: line, Reason: "missing time"}
+	}
+	t, err := parseTime(line[:sep])
+	if err != nil {
+		return e, &ParseError{Line: line, Reason: "invalid time"}
+	}
+	e.Time = t
+	rest := line[sep+1:]
+
+	sep = strings.IndexByte(rest, ' ')
+	if sep < 0 {
+		return e, &ParseError{Line: line, Reason: "missing level"}
+	}
+	e.Level = rest[:sep]
+	// the space before the special section is kept, the message may be empty
+	rest = rest[sep:]
+
+	fieldsStart := jsonStart(rest)
+	if fieldsStart < 0 {
+		return e, &ParseError{Line: line, Reason: "missing fields"}
+	}
+	d := json.NewDecoder(strings.NewReader(rest[fieldsStart:]))
+	d.UseNumber()
+	if err := d.Decode(&e.Fields); err != nil {
+		return e, &ParseError{Line: line, Reason: "invalid fields"}
+	}
+	rest = rest[:fieldsStart-len(">##")]
+
+	msgEnd, specialStart, version := specialStart(rest)
+	if msgEnd < 0 {
+		return e, &ParseError{Line: line, Reason: "missing special keys"}
+	}
+	e.Version = version
+	special, ok := parseSpecial(rest[specialStart:], version)
+	if !ok {
+		return e, &ParseError{Line: line, Reason: "invalid special keys"}
+	}
+	e.Special = special
+
+	msg := strings.TrimPrefix(rest[:msgEnd], " ")
+	if version == zaplog.FormatV2 {
+		name, m, ok := cutV2LoggerName(msg)
+		if !ok {
+			return e, &ParseError{Line: line, Reason: "invalid logger name"}
+		}
+		e.LoggerName, msg = name, m
+	} else if sep := strings.Index(msg, " - "); po.loggerNames && sep > 0 && !strings.Contains(msg[:sep], " ") {
+		e.LoggerName = msg[:sep]
+		msg = msg[sep+len(" - "):]
+	}
+	e.Message = msgUnescaper.Replace(msg)
+
+	return e, nil
+}
+
+// LoggerNames sets whether the v1 log lines may start with a logger name, enabled by default.
+// Disabling it keeps the messages of loggers without names intact, eg. "failed - retrying".
+func LoggerNames(enabled bool) ParseOption {
+	return func(po *ParseOptions) {
+		po.loggerNames = enabled
+	}
+}
+
+// cutV2LoggerName splits msg into the path escaped logger name and the message, see zaplog.FormatV2.
+func cutV2LoggerName(msg string) (name string, rest string, ok bool) {
+	if strings.HasPrefix(msg, "- ") {
+		return "", msg[len("- "):], true
+	}
+
+	sep := strings.Index(msg, " - ")
+	if sep <= 0 {
+		return "", msg, false
+	}
+	name, err := url.PathUnescape(msg[:sep])
+	if err != nil {
+		return "", msg, false
+	}
+
+	return name, msg[sep+len(" - "):], true
+}
+
+// parseTime parses the RFC 3339 times and the epoch times of zaplog.TimeFormatEpoch, zaplog.TimeFormatEpochMillis
+// and zaplog.TimeFormatEpochNanos. The epoch integers are parsed as milliseconds if they have 13 to 15 digits,
+// as nanoseconds if they have more digits and as seconds otherwise.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+
+	sec, frac := s, ""
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		sec, frac = s[:dot], s[dot+1:]
+	}
+	if len(frac) > 9 {
+		return time.Time{}, errors.New("dliverlog: invalid epoch time")
+	}
+
+	n, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var nanos int64
+	if frac != "" {
+		if nanos, err = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64); err != nil || nanos < 0 {
+			return time.Time{}, errors.New("dliverlog: invalid epoch time")
+		}
+		return time.Unix(n, nanos), nil
+	}
+
+	digits := len(strings.TrimPrefix(sec, "-"))
+	switch {
+	case digits >= 16:
+		return time.Unix(0, n), nil
+	case digits >= 13:
+		return time.Unix(0, n*int64(time.Millisecond)), nil
+	default:
+		return time.Unix(n, 0), nil
+	}
+}
+
+// jsonStart returns the index of the fields json in s: the leftmost '{' after a '>##' which starts a valid json suffix.
+func jsonStart(s string) int {
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], ">##{")
+		if i < 0 {
+			return -1
+		}
+		start := offset + i + len(">##")
+		if json.Valid(bytes.TrimSpace([]byte(s[start:]))) {
+			return start
+		}
+		offset = start
+	}
+}
+
+// specialStart returns the end of the message and the start of the special section in s (which ends before '>##').
+// The rightmost section start with valid content is used, because the message may contain anything.
+func specialStart(s string) (msgEnd int, start int, version int) {
+	for end := len(s); end > 0; {
+		i := strings.LastIndex(s[:end], " ##")
+		if i < 0 {
+			return -1, -1, 0
+		}
+
+		switch {
+		case strings.HasPrefix(s[i:], " ##<"):
+			if _, ok := parseSpecial(s[i+len(" ##<"):], zaplog.FormatV1); ok {
+				return i, i + len(" ##<"), zaplog.FormatV1
+			}
+		case strings.HasPrefix(s[i:], " ##v2<"):
+			if _, ok := parseSpecial(s[i+len(" ##v2<"):], zaplog.FormatV2); ok {
+				return i, i + len(" ##v2<"), zaplog.FormatV2
+			}
+		}
+		end = i
+	}
+
+	return -1, -1, 0
+}
+
+func parseSpecial(s string, version int) (map[string]string, bool) {
+	special := make(map[string]string)
+	for _, pair := range strings.Split(s, ";") {
+		if pair == "" {
+			continue
+		}
+
+		sep := strings.IndexByte(pair, '=')
+		if sep <= 0 {
+			return nil, false
+		}
+		k, v := pair[:sep], pair[sep+1:]
+		if version == zaplog.FormatV2 {
+			var err error
+			if k, err = zaplog.UnescapeSpecial(k); err != nil {
+				return nil, false
+			}
+			if v, err = zaplog.UnescapeSpecial(v); err != nil {
+				return nil, false
+			}
+		} else {
+			k = msgUnescaper.Replace(k)
+			v = msgUnescaper.Replace(v)
+		}
+		special[k] = v
+	}
+
+	return special, true
+}
+
+// Scanner reads log lines (or MessagePack frames, see NewMsgpackScanner) from an io.Reader. Malformed lines don't stop the scanning, see ParseErr.
+type Scanner struct {
+	scanner  *bufio.Scanner
+	parse    func(token []byte) (*Entry, error)
+	entry    *Entry
+	parseErr error
+}
+
+// NewScanner creates a Scanner reading log lines from r, the lines are parsed with the options by Parse.
+func NewScanner(r io.Reader, options ...ParseOption) *Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	return &Scanner{scanner: s, parse: func(line []byte) (*Entry, error) {
+		return Parse(string(line), options...)
+	}}
+}
+
+// Scan advances to the next non-empty line, it returns false at the end of the input or on read errors.
+func (s *Scanner) Scan() bool {
+	for s.scanner.Scan() {
+		token := s.scanner.Bytes()
+		if len(bytes.TrimSpace(token)) == 0 {
+			continue
+		}
+
+		s.entry, s.parseErr = s.parse(token)
+		return true
+	}
+
+	return false
+}
+
+// Entry returns the entry parsed from the current line. For malformed lines only the successfully parsed parts
+// and the Raw line are filled.
+func (s *Scanner) Entry() *Entry {
+	return s.entry
+}
+
+// ParseErr returns the parse error of the current line.
+func (s *Scanner) ParseErr() error {
+	return s.parseErr
+}
+
+// Err returns the first non-EOF read error.
+func (s *Scanner) Err() error {
+	return s.scanner.Err()
+}
@@ -0,0 +1,223 @@
+package dliverlog
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/proemergotech/log/v3/zaplog"
+)
+
+func encodeLine(t *testing.T, entry zapcore.Entry, fields []zapcore.Field, options ...zaplog.EncoderOption) string {
+	t.Helper()
+
+	enc, err := zaplog.NewEncoder([]string{"correlation_id", "token"}, options...)(zap.NewProductionEncoderConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := enc.EncodeEntry(entry, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	entryTime := time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)
+
+	tests := []struct {
+		name        string
+		encoder     []zaplog.EncoderOption
+		parser      []ParseOption
+		level       zapcore.Level
+		loggerName  string
+		message     string
+		fields      []zapcore.Field
+		wantSpecial map[string]string
+		wantFields  map[string]interface{}
+	}{
+		{
+			name:        "v1 with logger name",
+			loggerName:  "db",
+			message:     "connected",
+			fields:      []zapcore.Field{zap.String("correlation_id", "abc"), zap.Int("port", 5432)},
+			wantSpecial: map[string]string{"correlation_id": "abc"},
+			wantFields:  map[string]interface{}{"port": json.Number("5432")},
+		},
+		{
+			name:    "v1 without logger name",
+			parser:  []ParseOption{LoggerNames(false)},
+			message: "failed - retrying",
+		},
+		{
+			name:    "v1 multiline message",
+			level:   zapcore.WarnLevel,
+			message: "first\nsecond\r\nthird",
+		},
+		{
+			name:    "v1 epoch time",
+			encoder: []zaplog.EncoderOption{zaplog.TimeFormat(zaplog.TimeFormatEpochNanos)},
+			message: "hello",
+		},
+		{
+			name:    "v1 error level names",
+			level:   zapcore.PanicLevel,
+			message: "boom",
+		},
+		{
+			name:    "v2 without logger name",
+			encoder: []zaplog.EncoderOption{zaplog.FormatVersion(zaplog.FormatV2)},
+			message: "failed - retrying",
+		},
+		{
+			name:       "v2 logger name with spaces",
+			encoder:    []zaplog.EncoderOption{zaplog.FormatVersion(zaplog.FormatV2)},
+			loggerName: "my - logger",
+			message:    "- starts with a dash",
+		},
+		{
+			name:       "v2 special values",
+			encoder:    []zaplog.EncoderOption{zaplog.FormatVersion(zaplog.FormatV2)},
+			loggerName: "http",
+			message:    "request ##<fake=1;>##{} done",
+			fields: []zapcore.Field{
+				zap.String("token", "a=b;c>d%e\nf"),
+				zap.Int("correlation_id", 42),
+				zap.Strings("tags", []string{"x", "y"}),
+			},
+			wantSpecial: map[string]string{"token": "a=b;c>d%e\nf", "correlation_id": "42"},
+			wantFields:  map[string]interface{}{"tags": []interface{}{"x", "y"}},
+		},
+		{
+			name:    "v2 empty message",
+			encoder: []zaplog.EncoderOption{zaplog.FormatVersion(zaplog.FormatV2)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line := encodeLine(t, zapcore.Entry{
+				Level:      test.level,
+				Time:       entryTime,
+				LoggerName: test.loggerName,
+				Message:    test.message,
+			}, test.fields, test.encoder...)
+
+			e, err := Parse(line, test.parser...)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			if !e.Time.Equal(entryTime) {
+				t.Errorf("expected time %v, got %v", entryTime, e.Time)
+			}
+			if wantLevel := levelName(test.level); e.Level != wantLevel {
+				t.Errorf("expected level %v, got %v", wantLevel, e.Level)
+			}
+			if e.LoggerName != test.loggerName {
+				t.Errorf("expected logger name %q, got %q", test.loggerName, e.LoggerName)
+			}
+			if e.Message != test.message {
+				t.Errorf("expected message %q, got %q", test.message, e.Message)
+			}
+
+			wantSpecial := test.wantSpecial
+			if wantSpecial == nil {
+				wantSpecial = map[string]string{}
+			}
+			if !reflect.DeepEqual(e.Special, wantSpecial) {
+				t.Errorf("expected special keys %v, got %v", wantSpecial, e.Special)
+			}
+
+			wantFields := test.wantFields
+			if wantFields == nil {
+				wantFields = map[string]interface{}{}
+			}
+			if !reflect.DeepEqual(e.Fields, wantFields) {
+				t.Errorf("expected fields %v, got %v", wantFields, e.Fields)
+			}
+			if e.Raw != strings.TrimSuffix(line, "\n") {
+				t.Errorf("unexpected raw line %q", e.Raw)
+			}
+		})
+	}
+}
+
+func levelName(l zapcore.Level) string {
+	if l > zapcore.ErrorLevel {
+		return "error"
+	}
+
+	return l.String()
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		line   string
+		reason string
+	}{
+		{line: "", reason: "missing time"},
+		{line: "yesterday info hello ##<>##{}", reason: "invalid time"},
+		{line: "2020-01-02T03:04:05Z", reason: "missing time"},
+		{line: "2020-01-02T03:04:05Z info", reason: "missing level"},
+		{line: "2020-01-02T03:04:05Z info hello", reason: "missing fields"},
+		{line: "2020-01-02T03:04:05Z info hello ##<>##{", reason: "missing fields"},
+		{line: "2020-01-02T03:04:05Z info hello >##{}", reason: "missing special keys"},
+		{line: "2020-01-02T03:04:05Z info hello ##<a>##{}", reason: "missing special keys"},
+		{line: "2020-01-02T03:04:05Z info hello ##v2<a=%zz>##{}", reason: "missing special keys"},
+		{line: "2020-01-02T03:04:05Z info hello ##v2<>##{}", reason: "invalid logger name"},
+		{line: "2020-01-02T03:04:05Z info a%zz - hello ##v2<>##{}", reason: "invalid logger name"},
+	}
+
+	for _, test := range tests {
+		e, err := Parse(test.line)
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%q: expected a ParseError, got %v", test.line, err)
+			continue
+		}
+		if parseErr.Reason != test.reason {
+			t.Errorf("%q: expected reason %q, got %q", test.line, test.reason, parseErr.Reason)
+		}
+		if e == nil || e.Raw != test.line {
+			t.Errorf("%q: expected the raw line in the entry", test.line)
+		}
+	}
+}
+
+func TestScanner(t *testing.T) {
+	input := strings.Join([]string{
+		"2020-01-02T03:04:05Z info db - first ##<>##{}",
+		"",
+		"not a log line",
+		"2020-01-02T03:04:05Z error second - part ##<>##{\"a\":1}",
+	}, "\n")
+
+	s := NewScanner(strings.NewReader(input), LoggerNames(false))
+
+	var messages []string
+	var parseErrors int
+	for s.Scan() {
+		if s.ParseErr() != nil {
+			parseErrors++
+			continue
+		}
+		messages = append(messages, s.Entry().Message)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"db - first", "second - part"}; !reflect.DeepEqual(messages, want) {
+		t.Errorf("expected messages %q, got %q", want, messages)
+	}
+	if parseErrors != 1 {
+		t.Errorf("expected 1 parse error, got %v", parseErrors)
+	}
+}
@@ -103,13 +103,19 @@ func DebugMiddleware(l log.Logger, logRequest bool, logResponse bool) echo.Middl
 }
 
 // RecoveryMiddleware returns a middleware which will log in our format an add the panic stacktrace to the json.
+// Panics with errors implementing log.Leveler will be logged on their declared level.
 func RecoveryMiddleware(l log.Logger) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(eCtx echo.Context) error {
 			defer func() {
 				if r := recover(); r != nil {
+					level := log.ErrorLevel
+					if rErr, ok := r.(error); ok {
+						level = log.LevelOf(rErr, log.ErrorLevel)
+					}
+
 					err := errors.Errorf("%+v", r)
-					l.Error(context.Background(), "Echo recovered from panic", "error", err)
+					log.LevelFunc(l, level)(context.Background(), "Echo recovered from panic", "error", err)
 					eCtx.Error(err)
 				}
 			}()
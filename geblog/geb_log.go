@@ -6,11 +6,12 @@ import (
 	"github.com/proemergotech/log/v3"
 )
 
-// OnEventErrorMiddleware return a middleware which will log errors on ERROR level. s.
+// OnEventErrorMiddleware return a middleware which will log errors on ERROR level.
+// Errors implementing log.Leveler will be logged on their declared level.
 func OnEventErrorMiddleware(l log.Logger) geb.Middleware {
 	return func(e *geb.Event, next func(*geb.Event) error) error {
 		if err := next(e); err != nil {
-			l.Error(e.Context(), err.Error(), "error", err)
+			log.LevelFunc(l, log.LevelOf(err, log.ErrorLevel))(e.Context(), err.Error(), "error", err)
 		}
 
 		return nil
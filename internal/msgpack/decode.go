@@ -0,0 +1,295 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const maxDepth = 100
+
+// ErrShortBuffer is returned by Decode and Skip if the data ends in the middle of a value.
+var ErrShortBuffer = errors.New("msgpack: unexpected end of data")
+
+// Decode decodes the first value of b and returns it with its encoded size.
+// The values are decoded as nil, bool, int64, uint64, float64, string, []byte, time.Time,
+// []interface{} or map[string]interface{}, the non-string map keys are formatted with fmt.Sprint.
+// The extensions other than the timestamps are decoded as []byte.
+func Decode(b []byte) (interface{}, int, error) {
+	d := decoder{b: b}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return v, d.pos, nil
+}
+
+// Skip returns the encoded size of the first value of b, without decoding it.
+func Skip(b []byte) (int, error) {
+	d := decoder{b: b, skip: true}
+	if _, err := d.value(0); err != nil {
+		return 0, err
+	}
+
+	return d.pos, nil
+}
+
+type decoder struct {
+	b    []byte
+	pos  int
+	skip bool
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("msgpack: maximum nesting depth exceeded")
+	}
+
+	c, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0x80 && c <= 0x8f:
+		return d.mapValue(int(c&0x0f), depth)
+	case c >= 0x90 && c <= 0x9f:
+		return d.array(int(c&0x0f), depth)
+	case c >= 0xa0 && c <= 0xbf:
+		return d.str(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(c - 0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return d.bin(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.length(c - 0xc7)
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(n)
+	case 0xca:
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (c - 0xcc))
+	case 0xd0:
+		v, err := d.uint(1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := d.uint(2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := d.uint(4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := d.uint(8)
+		return int64(v), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(c - 0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return d.str(n)
+	case 0xdc, 0xdd:
+		n, err := d.length(c - 0xdc + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(c - 0xde + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.mapValue(n, depth)
+	}
+
+	return nil, errors.Errorf("msgpack: invalid format byte 0x%x", c)
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.pos >= len(d.b) {
+		return 0, ErrShortBuffer
+	}
+	c := d.b[d.pos]
+	d.pos++
+
+	return c, nil
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.b)-d.pos < n {
+		return nil, ErrShortBuffer
+	}
+	b := d.b[d.pos : d.pos+n]
+	d.pos += n
+
+	return b, nil
+}
+
+func (d *decoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// length reads a length of 1, 2 or 4 bytes, sizeClass is 0, 1 or 2 respectively.
+func (d *decoder) length(sizeClass byte) (int, error) {
+	v, err := d.uint(1 << sizeClass)
+	if err != nil {
+		return 0, err
+	}
+	if v > uint64(len(d.b)) {
+		return 0, ErrShortBuffer
+	}
+
+	return int(v), nil
+}
+
+func (d *decoder) str(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil || d.skip {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func (d *decoder) bin(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil || d.skip {
+		return nil, err
+	}
+
+	return append([]byte(nil), b...), nil
+}
+
+func (d *decoder) ext(n int) (interface{}, error) {
+	typ, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	b, err := d.next(n)
+	if err != nil || d.skip {
+		return nil, err
+	}
+
+	if int8(typ) != TimeExtType {
+		return append([]byte(nil), b...), nil
+	}
+
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(b)), 0), nil
+	case 8:
+		v := binary.BigEndian.Uint64(b)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(b)
+		sec := int64(binary.BigEndian.Uint64(b[4:]))
+		return time.Unix(sec, int64(nsec)), nil
+	}
+
+	return nil, errors.Errorf("msgpack: invalid timestamp length %v", n)
+}
+
+func (d *decoder) array(n int, depth int) (interface{}, error) {
+	var a []interface{}
+	if !d.skip {
+		// the length is not trusted for preallocation, every element is at least 1 byte
+		a = make([]interface{}, 0, minInt(n, len(d.b)-d.pos))
+	}
+
+	for i := 0; i < n; i++ {
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if !d.skip {
+			a = append(a, v)
+		}
+	}
+
+	if d.skip {
+		return nil, nil
+	}
+
+	return a, nil
+}
+
+func (d *decoder) mapValue(n int, depth int) (interface{}, error) {
+	var m map[string]interface{}
+	if !d.skip {
+		m = make(map[string]interface{}, minInt(n, (len(d.b)-d.pos)/2))
+	}
+
+	for i := 0; i < n; i++ {
+		k, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if d.skip {
+			continue
+		}
+
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		m[key] = v
+	}
+
+	if d.skip {
+		return nil, nil
+	}
+
+	return m, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
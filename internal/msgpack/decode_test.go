@@ -0,0 +1,87 @@
+package msgpack
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDecodeShortBuffer(t *testing.T) {
+	values := [][]byte{
+		AppendInt(nil, -200),
+		AppendUint(nil, 1<<40),
+		AppendFloat32(nil, 1),
+		AppendFloat64(nil, 1),
+		AppendString(nil, "hello"),
+		AppendString(nil, string(make([]byte, 300))),
+		AppendBytes(nil, []byte{1, 2, 3}),
+		AppendTime(nil, time.Unix(1600000000, 1)),
+		AppendTime(nil, time.Unix(-1, 0)),
+		AppendString(AppendArrayHeader(nil, 1), "a"),
+		AppendInt(AppendString(AppendMapHeader(nil, 1), "a"), 1),
+	}
+
+	for _, b := range values {
+		for i := 0; i < len(b); i++ {
+			if _, _, err := Decode(b[:i]); err != ErrShortBuffer {
+				t.Errorf("% x: expected ErrShortBuffer, got %v", b[:i], err)
+			}
+			if _, err := Skip(b[:i]); err != ErrShortBuffer {
+				t.Errorf("% x: expected ErrShortBuffer from Skip, got %v", b[:i], err)
+			}
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded []byte
+	}{
+		{name: "never used format", encoded: []byte{0xc1}},
+		{name: "invalid timestamp length", encoded: []byte{0xd5, 0xff, 0, 0}},
+		{name: "oversized length", encoded: []byte{0xdb, 0xff, 0xff, 0xff, 0xff}},
+		{name: "oversized array", encoded: []byte{0xdd, 0xff, 0xff, 0xff, 0xff, 0xc0}},
+		{name: "too deep nesting", encoded: bytes.Repeat([]byte{0x91}, maxDepth+2)},
+	}
+
+	for _, test := range tests {
+		if _, _, err := Decode(test.encoded); err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+}
+
+func TestDecodeExt(t *testing.T) {
+	// the extensions other than the timestamps are kept as their data
+	v, n, err := Decode([]byte{0xd5, 0x01, 0xab, 0xcd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("expected size 4, got %v", n)
+	}
+	if b, ok := v.([]byte); !ok || !bytes.Equal(b, []byte{0xab, 0xcd}) {
+		t.Errorf("expected the extension data, got %#v", v)
+	}
+}
+
+func TestSkipSequence(t *testing.T) {
+	var b []byte
+	b = AppendString(b, "first")
+	b = AppendInt(AppendString(AppendMapHeader(b, 1), "nested"), -1)
+	b = AppendTime(b, time.Unix(1600000000, 5))
+	b = AppendNil(b)
+
+	var count int
+	for pos := 0; pos < len(b); count++ {
+		n, err := Skip(b[pos:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		pos += n
+	}
+	if count != 4 {
+		t.Errorf("expected 4 values, got %v", count)
+	}
+}
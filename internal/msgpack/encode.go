@@ -0,0 +1,160 @@
+// Package msgpack implements the subset of the MessagePack format (https://msgpack.org) used by the zaplog MessagePack encoder.
+package msgpack
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// TimeExtType is the extension type of the MessagePack timestamps.
+const TimeExtType = -1
+
+func AppendNil(b []byte) []byte {
+	return append(b, 0xc0)
+}
+
+func AppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+
+	return append(b, 0xc2)
+}
+
+// AppendInt appends v in the most compact integer format.
+func AppendInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return AppendUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return appendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return appendUint32(append(b, 0xd2), uint32(v))
+	default:
+		return appendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+// AppendUint appends v in the most compact integer format.
+func AppendUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return appendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return appendUint32(append(b, 0xce), uint32(v))
+	default:
+		return appendUint64(append(b, 0xcf), v)
+	}
+}
+
+func AppendFloat32(b []byte, v float32) []byte {
+	return appendUint32(append(b, 0xca), math.Float32bits(v))
+}
+
+func AppendFloat64(b []byte, v float64) []byte {
+	return appendUint64(append(b, 0xcb), math.Float64bits(v))
+}
+
+func AppendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n <= 31:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xdb), uint32(n))
+	}
+
+	return append(b, s...)
+}
+
+// AppendBytes appends v in the bin format.
+func AppendBytes(b []byte, v []byte) []byte {
+	n := len(v)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xc6), uint32(n))
+	}
+
+	return append(b, v...)
+}
+
+// AppendArrayHeader appends the header of an array with n elements, the elements must be appended after it.
+func AppendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return appendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+// AppendMapHeader appends the header of a map with n key-value pairs, the keys and values must be appended after it.
+func AppendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, 0xde), uint16(n))
+	default:
+		return appendUint32(append(b, 0xdf), uint32(n))
+	}
+}
+
+// AppendTime appends t as a timestamp extension, in the most compact format.
+func AppendTime(b []byte, t time.Time) []byte {
+	sec := t.Unix()
+	nsec := uint32(t.Nanosecond())
+
+	switch {
+	case sec >= 0 && sec <= math.MaxUint32 && nsec == 0:
+		// timestamp 32
+		b = append(b, 0xd6, byte(TimeExtType&0xff))
+		return appendUint32(b, uint32(sec))
+	case sec >= 0 && sec < 1<<34:
+		// timestamp 64
+		b = append(b, 0xd7, byte(TimeExtType&0xff))
+		return appendUint64(b, uint64(nsec)<<34|uint64(sec))
+	default:
+		// timestamp 96
+		b = append(b, 0xc7, 12, byte(TimeExtType&0xff))
+		b = appendUint32(b, nsec)
+		return appendUint64(b, uint64(sec))
+	}
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,129 @@
+package msgpack
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppend(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []byte
+		want []byte
+	}{
+		{name: "nil", got: AppendNil(nil), want: []byte{0xc0}},
+		{name: "false", got: AppendBool(nil, false), want: []byte{0xc2}},
+		{name: "true", got: AppendBool(nil, true), want: []byte{0xc3}},
+		{name: "positive fixint", got: AppendInt(nil, 127), want: []byte{0x7f}},
+		{name: "negative fixint", got: AppendInt(nil, -32), want: []byte{0xe0}},
+		{name: "int 8", got: AppendInt(nil, -33), want: []byte{0xd0, 0xdf}},
+		{name: "int 16", got: AppendInt(nil, math.MinInt8-1), want: []byte{0xd1, 0xff, 0x7f}},
+		{name: "int 32", got: AppendInt(nil, math.MinInt16-1), want: []byte{0xd2, 0xff, 0xff, 0x7f, 0xff}},
+		{name: "int 64", got: AppendInt(nil, math.MinInt32-1), want: []byte{0xd3, 0xff, 0xff, 0xff, 0xff, 0x7f, 0xff, 0xff, 0xff}},
+		{name: "positive int", got: AppendInt(nil, 128), want: []byte{0xcc, 0x80}},
+		{name: "uint 8", got: AppendUint(nil, 255), want: []byte{0xcc, 0xff}},
+		{name: "uint 16", got: AppendUint(nil, 256), want: []byte{0xcd, 0x01, 0x00}},
+		{name: "uint 32", got: AppendUint(nil, math.MaxUint16+1), want: []byte{0xce, 0x00, 0x01, 0x00, 0x00}},
+		{name: "uint 64", got: AppendUint(nil, math.MaxUint32+1), want: []byte{0xcf, 0, 0, 0, 0x01, 0, 0, 0, 0}},
+		{name: "float 32", got: AppendFloat32(nil, 1.5), want: []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}},
+		{name: "float 64", got: AppendFloat64(nil, 1.5), want: []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{name: "fixstr", got: AppendString(nil, "abc"), want: []byte{0xa3, 'a', 'b', 'c'}},
+		{name: "str 8", got: AppendString(nil, strings.Repeat("a", 32))[:2], want: []byte{0xd9, 32}},
+		{name: "str 16", got: AppendString(nil, strings.Repeat("a", 256))[:3], want: []byte{0xda, 0x01, 0x00}},
+		{name: "str 32", got: AppendString(nil, strings.Repeat("a", math.MaxUint16+1))[:5], want: []byte{0xdb, 0, 0x01, 0, 0}},
+		{name: "bin 8", got: AppendBytes(nil, []byte{1, 2}), want: []byte{0xc4, 2, 1, 2}},
+		{name: "bin 16", got: AppendBytes(nil, make([]byte, 256))[:3], want: []byte{0xc5, 0x01, 0x00}},
+		{name: "fixarray", got: AppendArrayHeader(nil, 15), want: []byte{0x9f}},
+		{name: "array 16", got: AppendArrayHeader(nil, 16), want: []byte{0xdc, 0, 16}},
+		{name: "array 32", got: AppendArrayHeader(nil, math.MaxUint16+1), want: []byte{0xdd, 0, 0x01, 0, 0}},
+		{name: "fixmap", got: AppendMapHeader(nil, 1), want: []byte{0x81}},
+		{name: "map 16", got: AppendMapHeader(nil, 16), want: []byte{0xde, 0, 16}},
+		{name: "map 32", got: AppendMapHeader(nil, math.MaxUint16+1), want: []byte{0xdf, 0, 0x01, 0, 0}},
+		{name: "timestamp 32", got: AppendTime(nil, time.Unix(1, 0)), want: []byte{0xd6, 0xff, 0, 0, 0, 1}},
+		{name: "timestamp 64", got: AppendTime(nil, time.Unix(1, 1)), want: []byte{0xd7, 0xff, 0, 0, 0, 0x04, 0, 0, 0, 1}},
+		{name: "timestamp 96", got: AppendTime(nil, time.Unix(-1, 1)), want: []byte{0xc7, 12, 0xff, 0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, test := range tests {
+		if !bytes.Equal(test.got, test.want) {
+			t.Errorf("%v: expected % x, got % x", test.name, test.want, test.got)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded []byte
+		want    interface{}
+	}{
+		{name: "nil", encoded: AppendNil(nil), want: nil},
+		{name: "bool", encoded: AppendBool(nil, true), want: true},
+		{name: "min int", encoded: AppendInt(nil, math.MinInt64), want: int64(math.MinInt64)},
+		{name: "negative int", encoded: AppendInt(nil, -200), want: int64(-200)},
+		{name: "small int", encoded: AppendInt(nil, 5), want: int64(5)},
+		{name: "max uint", encoded: AppendUint(nil, math.MaxUint64), want: uint64(math.MaxUint64)},
+		{name: "uint", encoded: AppendUint(nil, 300), want: uint64(300)},
+		{name: "float 32", encoded: AppendFloat32(nil, 0.25), want: 0.25},
+		{name: "float 64", encoded: AppendFloat64(nil, math.Pi), want: math.Pi},
+		{name: "empty string", encoded: AppendString(nil, ""), want: ""},
+		{name: "long string", encoded: AppendString(nil, strings.Repeat("é", 40000)), want: strings.Repeat("é", 40000)},
+		{name: "bytes", encoded: AppendBytes(nil, []byte{0, 1, 2}), want: []byte{0, 1, 2}},
+		{name: "timestamp 32", encoded: AppendTime(nil, time.Unix(1600000000, 0)), want: time.Unix(1600000000, 0)},
+		{name: "timestamp 64", encoded: AppendTime(nil, time.Unix(1600000000, 123456789)), want: time.Unix(1600000000, 123456789)},
+		{name: "timestamp 96", encoded: AppendTime(nil, time.Unix(-1600000000, 999999999)), want: time.Unix(-1600000000, 999999999)},
+		{
+			name:    "array",
+			encoded: AppendString(AppendInt(AppendArrayHeader(nil, 2), -1), "a"),
+			want:    []interface{}{int64(-1), "a"},
+		},
+		{
+			name: "nested map",
+			encoded: AppendArrayHeader(
+				AppendString(
+					AppendUint(
+						AppendString(AppendMapHeader(nil, 2), "a"),
+						1,
+					),
+					"b",
+				),
+				0,
+			),
+			want: map[string]interface{}{"a": int64(1), "b": []interface{}{}},
+		},
+		{
+			name:    "non-string map key",
+			encoded: AppendBool(AppendInt(AppendMapHeader(nil, 1), 7), false),
+			want:    map[string]interface{}{"7": false},
+		},
+	}
+
+	for _, test := range tests {
+		// a trailing value is not part of the decoded one
+		b := AppendNil(test.encoded)
+
+		got, n, err := Decode(b)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+		if n != len(test.encoded) {
+			t.Errorf("%v: expected size %v, got %v", test.name, len(test.encoded), n)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: expected %#v, got %#v", test.name, test.want, got)
+		}
+
+		n, err = Skip(b)
+		if err != nil {
+			t.Errorf("%v: unexpected skip error: %v", test.name, err)
+		}
+		if n != len(test.encoded) {
+			t.Errorf("%v: expected skipped size %v, got %v", test.name, len(test.encoded), n)
+		}
+	}
+}
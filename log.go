@@ -2,7 +2,6 @@ package log
 
 import (
 	"context"
-	"unicode/utf8"
 )
 
 const (
@@ -28,25 +27,3 @@ type Logger interface {
 type ContextMapper interface {
 	Values(ctx context.Context) map[string]string
 }
-
-func Truncate(str string, length int, concat string) string {
-	if len(str) <= length {
-		return str
-	}
-
-	finalLength := length - len(concat)
-	headLength := finalLength/2 + finalLength%2
-	tailLength := finalLength / 2
-
-	for !utf8.RuneStart(str[headLength]) {
-		headLength--
-	}
-	for !utf8.RuneStart(str[tailLength]) {
-		tailLength++
-	}
-
-	head := str[0:headLength]
-	tail := str[len(str)-tailLength:]
-
-	return head + concat + tail
-}
@@ -0,0 +1,243 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	ErrorCode   = "error_code"
+	ErrorStatus = "error_status"
+	ErrorKind   = "error_kind"
+	ErrorPublic = "error_public"
+	ErrorChain  = "error_chain"
+)
+
+const (
+	ErrorKindRetryable = "retryable"
+	ErrorKindPermanent = "permanent"
+)
+
+// Coder is implemented by errors which have a machine readable error code.
+type Coder interface {
+	Code() string
+}
+
+// HTTPStatuser is implemented by errors which have a corresponding http status code.
+type HTTPStatuser interface {
+	HTTPStatus() int
+}
+
+// Retryabler is implemented by errors which know whether the failed operation can be retried.
+type Retryabler interface {
+	Retryable() bool
+}
+
+// PublicMessager is implemented by errors which have a message that can be shown to the end users.
+type PublicMessager interface {
+	PublicMessage() string
+}
+
+// Leveler is implemented by errors which declare the level they should be logged on.
+// Expected errors (validation errors, not found errors, etc.) can use it to avoid being logged on error level.
+type Leveler interface {
+	Level() int
+}
+
+type levelError struct {
+	error
+	level int
+}
+
+// WithLevel wraps err, so it will be logged on the given level by the middlewares of this library.
+// Returns nil if err is nil.
+func WithLevel(err error, level int) error {
+	if err == nil {
+		return nil
+	}
+
+	return &levelError{error: err, level: level}
+}
+
+// AsDebug wraps err, so it will be logged on debug level.
+func AsDebug(err error) error {
+	return WithLevel(err, DebugLevel)
+}
+
+// AsInfo wraps err, so it will be logged on info level.
+func AsInfo(err error) error {
+	return WithLevel(err, InfoLevel)
+}
+
+// AsWarn wraps err, so it will be logged on warn level.
+func AsWarn(err error) error {
+	return WithLevel(err, WarnLevel)
+}
+
+func (e *levelError) Level() int {
+	return e.level
+}
+
+func (e *levelError) Cause() error {
+	return e.error
+}
+
+func (e *levelError) Unwrap() error {
+	return e.error
+}
+
+// Format keeps the verbose formatting (eg. stack traces) of the wrapped error.
+func (e *levelError) Format(s fmt.State, verb rune) {
+	if f, ok := e.error.(fmt.Formatter); ok {
+		f.Format(s, verb)
+		return
+	}
+
+	switch verb {
+	case 'v', 's':
+		_, _ = io.WriteString(s, e.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// LevelOf returns the level declared by the first Leveler in the err chain.
+// Returns defaultLevel if there is no such error, or the declared level is above ErrorLevel.
+func LevelOf(err error, defaultLevel int) int {
+	for ; err != nil; err = unwrap(err) {
+		if lErr, ok := err.(Leveler); ok {
+			level := lErr.Level()
+			if level < DebugLevel || level > ErrorLevel {
+				return defaultLevel
+			}
+			return level
+		}
+	}
+
+	return defaultLevel
+}
+
+// ErrorMetadata returns the standard fields (ErrorCode, ErrorStatus, ErrorKind, ErrorPublic and ErrorChain)
+// extracted from the whole err chain. The first (outermost) value found is used for each field.
+// Besides the interfaces declared in this package, the StatusCode() int and Temporary() bool methods are recognised too.
+func ErrorMetadata(err error) []interface{} {
+	type intCoder interface {
+		Code() int
+	}
+
+	type statusCoder interface {
+		StatusCode() int
+	}
+
+	type temporary interface {
+		Temporary() bool
+	}
+
+	var code, kind, public string
+	var status int
+	var chain []string
+	for ; err != nil; err = unwrap(err) {
+		if msg := err.Error(); len(chain) == 0 || chain[len(chain)-1] != msg {
+			chain = append(chain, msg)
+		}
+
+		if code == "" {
+			switch e := err.(type) {
+			case Coder:
+				code = e.Code()
+			case intCoder:
+				code = strconv.Itoa(e.Code())
+			}
+		}
+
+		if status == 0 {
+			switch e := err.(type) {
+			case HTTPStatuser:
+				status = e.HTTPStatus()
+			case statusCoder:
+				status = e.StatusCode()
+			}
+		}
+
+		if kind == "" {
+			retryable, ok := false, false
+			switch e := err.(type) {
+			case Retryabler:
+				retryable, ok = e.Retryable(), true
+			case temporary:
+				retryable, ok = e.Temporary(), true
+			}
+			if ok && retryable {
+				kind = ErrorKindRetryable
+			} else if ok {
+				kind = ErrorKindPermanent
+			}
+		}
+
+		if public == "" {
+			if pErr, ok := err.(PublicMessager); ok {
+				public = pErr.PublicMessage()
+			}
+		}
+	}
+
+	var fields []interface{}
+	if code != "" {
+		fields = append(fields, ErrorCode, code)
+	}
+	if status != 0 {
+		fields = append(fields, ErrorStatus, status)
+	}
+	if kind != "" {
+		fields = append(fields, ErrorKind, kind)
+	}
+	if public != "" {
+		fields = append(fields, ErrorPublic, public)
+	}
+	if len(chain) > 1 {
+		fields = append(fields, ErrorChain, chain)
+	}
+
+	return fields
+}
+
+// unwrap returns the cause of err, supporting both the pkg/errors and the standard library way of wrapping.
+func unwrap(err error) error {
+	type causer interface {
+		Cause() error
+	}
+
+	type unwrapper interface {
+		Unwrap() error
+	}
+
+	switch e := err.(type) {
+	case causer:
+		return e.Cause()
+	case unwrapper:
+		return e.Unwrap()
+	default:
+		return nil
+	}
+}
+
+// LevelFunc returns the logging method of l for the given level.
+// Returns nil for unknown levels.
+func LevelFunc(l Logger, level int) func(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	switch level {
+	case DebugLevel:
+		return l.Debug
+	case InfoLevel:
+		return l.Info
+	case WarnLevel:
+		return l.Warn
+	case ErrorLevel:
+		return l.Error
+	case PanicLevel:
+		return l.Panic
+	default:
+		return nil
+	}
+}
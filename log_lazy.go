@@ -0,0 +1,44 @@
+package log
+
+import "fmt"
+
+// Lazy is a log field value, which will be evaluated only if the log entry is going to be emitted.
+// Useful for expensive debug fields:
+//
+//	logger.Debug(ctx, "request", "body", log.Lazy(func() interface{} { return dump(body) }))
+type Lazy func() interface{}
+
+// Resolve evaluates the lazy value. A panic in the lazy function is recovered and its description is returned as the value.
+func (l Lazy) Resolve() (v interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			v = fmt.Sprintf("!PANIC(lazy value: %v)", r)
+		}
+	}()
+
+	return l()
+}
+
+// ResolveLazy returns keysAndValues with the Lazy values resolved.
+// The passed slice is not modified, it's copied only if it contains Lazy values.
+func ResolveLazy(keysAndValues []interface{}) []interface{} {
+	var resolved []interface{}
+	for i, v := range keysAndValues {
+		lazy, ok := v.(Lazy)
+		if !ok {
+			continue
+		}
+
+		if resolved == nil {
+			resolved = make([]interface{}, len(keysAndValues))
+			copy(resolved, keysAndValues)
+		}
+		resolved[i] = lazy.Resolve()
+	}
+
+	if resolved == nil {
+		return keysAndValues
+	}
+
+	return resolved
+}
@@ -0,0 +1,150 @@
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	OperationID       = "operation_id"
+	ParentOperationID = "parent_operation_id"
+)
+
+const (
+	OperationStatusSuccess = "success"
+	OperationStatusSlow    = "slow"
+	OperationStatusError   = "error"
+)
+
+type operationKey struct{}
+
+type operationIDs struct {
+	id       string
+	parentID string
+}
+
+// Operation logs the duration and the outcome of an operation, see Start.
+type Operation struct {
+	ctx           context.Context
+	logger        Logger
+	name          string
+	ids           operationIDs
+	start         time.Time
+	slowThreshold time.Duration
+	keysAndValues []interface{}
+}
+
+type operationContextMapper struct {
+	ContextMapper
+}
+
+// Start starts measuring an operation, which must be finished by calling End on the returned Operation.
+// The returned context contains the id of the operation, operations started with it will be nested into this one.
+func Start(ctx context.Context, logger Logger, operation string, keysAndValues ...interface{}) (context.Context, *Operation) {
+	ids := operationIDs{id: newOperationID()}
+	if parent, ok := ctx.Value(operationKey{}).(operationIDs); ok {
+		ids.parentID = parent.id
+	}
+
+	ctx = context.WithValue(ctx, operationKey{}, ids)
+
+	return ctx, &Operation{
+		ctx:           ctx,
+		logger:        logger,
+		name:          operation,
+		ids:           ids,
+		start:         time.Now(),
+		keysAndValues: keysAndValues,
+	}
+}
+
+// SlowThreshold makes End log successful operations lasting longer than d on warn level.
+func (o *Operation) SlowThreshold(d time.Duration) *Operation {
+	o.slowThreshold = d
+
+	return o
+}
+
+// End logs the duration and the status of the operation, and returns the duration.
+// Failed operations (err != nil) are logged on error level, or on the level declared by err (see Leveler),
+// slow operations on warn level and successful operations on debug level.
+// The operation ids are added to the entry by the ContextMapper of the logger, see OperationContextMapper.
+func (o *Operation) End(err error, keysAndValues ...interface{}) time.Duration {
+	duration := time.Since(o.start)
+
+	level := DebugLevel
+	status := OperationStatusSuccess
+	message := "operation " + o.name + " finished"
+	switch {
+	case err != nil:
+		level = LevelOf(err, ErrorLevel)
+		status = OperationStatusError
+		message = "operation " + o.name + " failed: " + err.Error()
+	case o.slowThreshold > 0 && duration > o.slowThreshold:
+		level = WarnLevel
+		status = OperationStatusSlow
+	}
+
+	fields := make([]interface{}, 0, len(o.keysAndValues)+len(keysAndValues)+8)
+	fields = append(fields, "operation", o.name, "duration", duration, "status", status)
+	fields = append(fields, o.keysAndValues...)
+	fields = append(fields, keysAndValues...)
+	if err != nil {
+		fields = append(fields, "error", err)
+	}
+
+	LevelFunc(o.logger, level)(o.ctx, message, fields...)
+
+	return duration
+}
+
+// OperationValues returns the ids of the current operation stored in ctx, see Start.
+func OperationValues(ctx context.Context) map[string]string {
+	ids, ok := ctx.Value(operationKey{}).(operationIDs)
+	if !ok {
+		return nil
+	}
+
+	return ids.values()
+}
+
+// OperationContextMapper returns a ContextMapper which adds the ids of the current operation to the values of m.
+func OperationContextMapper(m ContextMapper) ContextMapper {
+	return &operationContextMapper{ContextMapper: m}
+}
+
+func (m *operationContextMapper) Values(ctx context.Context) map[string]string {
+	values := m.ContextMapper.Values(ctx)
+	ids := OperationValues(ctx)
+	if len(ids) == 0 {
+		return values
+	}
+
+	merged := make(map[string]string, len(values)+len(ids))
+	for k, v := range values {
+		merged[k] = v
+	}
+	for k, v := range ids {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+func (ids operationIDs) values() map[string]string {
+	values := map[string]string{OperationID: ids.id}
+	if ids.parentID != "" {
+		values[ParentOperationID] = ids.parentID
+	}
+
+	return values
+}
+
+func newOperationID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
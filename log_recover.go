@@ -0,0 +1,73 @@
+package log
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+type RecoverOption func(ro *RecoverOptions)
+
+type RecoverOptions struct {
+	rePanic bool
+	onPanic func(ctx context.Context, err error)
+}
+
+// Recover recovers a panic and logs it on error level with the stack trace of the panic.
+// Panics with errors implementing Leveler will be logged on their declared level.
+// It must be called directly by defer:
+//
+//	defer log.Recover(ctx, logger)
+func Recover(ctx context.Context, logger Logger, options ...RecoverOption) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	ro := RecoverOptions{}
+	for _, option := range options {
+		option(&ro)
+	}
+
+	var err error
+	level := ErrorLevel
+	if rErr, ok := r.(error); ok {
+		err = errors.WithStack(rErr)
+		level = LevelOf(rErr, ErrorLevel)
+	} else {
+		err = errors.Errorf("%+v", r)
+	}
+
+	LevelFunc(logger, level)(ctx, "recovered from panic: "+err.Error(), "error", err)
+
+	if ro.onPanic != nil {
+		ro.onPanic(ctx, err)
+	}
+
+	if ro.rePanic {
+		panic(r)
+	}
+}
+
+// Go runs fn in a new goroutine. Panics in fn will be recovered and logged, see Recover.
+func Go(ctx context.Context, logger Logger, fn func(ctx context.Context), options ...RecoverOption) {
+	go func() {
+		defer Recover(ctx, logger, options...)
+
+		fn(ctx)
+	}()
+}
+
+// RePanic makes Recover to panic again with the recovered value after logging it.
+func RePanic(enabled bool) RecoverOption {
+	return func(ro *RecoverOptions) {
+		ro.rePanic = enabled
+	}
+}
+
+// OnPanic sets a callback which will be called by Recover with the recovered panic, after logging it.
+func OnPanic(fn func(ctx context.Context, err error)) RecoverOption {
+	return func(ro *RecoverOptions) {
+		ro.onPanic = fn
+	}
+}
@@ -0,0 +1,73 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// recordLogger records the levels and messages of the logged entries.
+type recordLogger struct {
+	levels   []int
+	messages []string
+}
+
+func (rl *recordLogger) log(level int, msg string) {
+	rl.levels = append(rl.levels, level)
+	rl.messages = append(rl.messages, msg)
+}
+
+func (rl *recordLogger) Debug(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	rl.log(DebugLevel, msg)
+}
+
+func (rl *recordLogger) Info(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	rl.log(InfoLevel, msg)
+}
+
+func (rl *recordLogger) Warn(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	rl.log(WarnLevel, msg)
+}
+
+func (rl *recordLogger) Error(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	rl.log(ErrorLevel, msg)
+}
+
+func (rl *recordLogger) Panic(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	rl.log(PanicLevel, msg)
+}
+
+func (rl *recordLogger) IsDebug(ctx context.Context) bool {
+	return true
+}
+
+func (rl *recordLogger) Dump(msg string, v ...interface{}) {}
+
+func TestRecoverLevel(t *testing.T) {
+	tests := []struct {
+		name  string
+		panic interface{}
+		level int
+	}{
+		{name: "value", panic: "boom", level: ErrorLevel},
+		{name: "error", panic: errors.New("boom"), level: ErrorLevel},
+		{name: "leveler", panic: AsWarn(errors.New("boom")), level: WarnLevel},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logger := &recordLogger{}
+			func() {
+				defer Recover(context.Background(), logger)
+				panic(test.panic)
+			}()
+
+			if len(logger.levels) != 1 || logger.levels[0] != test.level {
+				t.Errorf("expected one entry on level %v, got levels %v", test.level, logger.levels)
+			}
+			if len(logger.messages) == 1 && logger.messages[0] != "recovered from panic: boom" {
+				t.Errorf("unexpected message %q", logger.messages[0])
+			}
+		})
+	}
+}
@@ -102,6 +102,11 @@ func (t *ThrottleLogger) Flush() {
 }
 
 func (t *ThrottleLogger) Debug(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	// don't keep the entry (and its Lazy values) if it won't be logged anyway
+	if !t.logger.IsDebug(ctx) {
+		return
+	}
+
 	t.throttleLogMessage(ctx, DebugLevel, msg, keysAndValues...)
 }
 
@@ -141,20 +146,8 @@ func (t *ThrottleLogger) throttleLogMessage(ctx context.Context, logLevel int, m
 }
 
 func (t *ThrottleLogger) logMessage(ctx context.Context, logLevel int, msg string, keysAndValues ...interface{}) {
-	var logFn func(context.Context, string, ...interface{})
-
-	switch logLevel {
-	case DebugLevel:
-		logFn = t.logger.Debug
-	case InfoLevel:
-		logFn = t.logger.Info
-	case WarnLevel:
-		logFn = t.logger.Warn
-	case ErrorLevel:
-		logFn = t.logger.Error
-	case PanicLevel:
-		logFn = t.logger.Panic
-	default:
+	logFn := LevelFunc(t.logger, logLevel)
+	if logFn == nil {
 		return
 	}
 
@@ -0,0 +1,96 @@
+package log
+
+import "unicode/utf8"
+
+// TruncateMode defines which part of a string is kept by the truncate functions.
+type TruncateMode uint8
+
+const (
+	// KeepHeadAndTail keeps the beginning and the end of the string, the concat string is put in the middle.
+	KeepHeadAndTail TruncateMode = iota
+	// KeepHead keeps the beginning of the string, the concat string is put at the end.
+	KeepHead
+	// KeepTail keeps the end of the string, the concat string is put at the beginning.
+	KeepTail
+)
+
+// Truncate shortens str to at most length bytes by cutting out its middle and replacing it with concat.
+// UTF-8 sequences are never split. If concat doesn't fit into length, it's omitted.
+func Truncate(str string, length int, concat string) string {
+	return TruncateBytes(str, length, concat, KeepHeadAndTail)
+}
+
+// TruncateBytes shortens str to at most length bytes, keeping the parts of str defined by mode.
+// The removed part is replaced with concat. UTF-8 sequences are never split. If concat doesn't fit into length, it's omitted.
+func TruncateBytes(str string, length int, concat string, mode TruncateMode) string {
+	if length < 0 {
+		length = 0
+	}
+	if len(str) <= length {
+		return str
+	}
+	if len(concat) > length {
+		concat = ""
+	}
+
+	headLength, tailLength := split(length-len(concat), mode)
+
+	for headLength > 0 && !utf8.RuneStart(str[headLength]) {
+		headLength--
+	}
+
+	tailStart := len(str) - tailLength
+	for tailStart < len(str) && !utf8.RuneStart(str[tailStart]) {
+		tailStart++
+	}
+
+	return str[:headLength] + concat + str[tailStart:]
+}
+
+// TruncateRunes shortens str to at most length runes, keeping the parts of str defined by mode.
+// The removed part is replaced with concat. If concat doesn't fit into length, it's omitted.
+func TruncateRunes(str string, length int, concat string, mode TruncateMode) string {
+	if length < 0 {
+		length = 0
+	}
+	runeCount := utf8.RuneCountInString(str)
+	if runeCount <= length {
+		return str
+	}
+	concatLength := utf8.RuneCountInString(concat)
+	if concatLength > length {
+		concat = ""
+		concatLength = 0
+	}
+
+	headLength, tailLength := split(length-concatLength, mode)
+
+	headEnd := runeOffset(str, headLength)
+	tailStart := runeOffset(str, runeCount-tailLength)
+
+	return str[:headEnd] + concat + str[tailStart:]
+}
+
+// split splits the available length between the head and the tail.
+func split(length int, mode TruncateMode) (head int, tail int) {
+	switch mode {
+	case KeepHead:
+		return length, 0
+	case KeepTail:
+		return 0, length
+	default:
+		return length/2 + length%2, length / 2
+	}
+}
+
+// runeOffset returns the byte offset of the n-th rune in str.
+func runeOffset(str string, n int) int {
+	for i := range str {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+
+	return len(str)
+}
@@ -0,0 +1,127 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"testing/quick"
+	"unicode/utf8"
+)
+
+var truncateConcats = []string{"", "...", "…", " [truncated] "}
+
+var truncateModes = []TruncateMode{KeepHeadAndTail, KeepHead, KeepTail}
+
+// truncated checks that out is made of a head of str, the concat (or nothing) and a tail of str, as allowed by mode.
+func truncated(str string, out string, concat string, mode TruncateMode) bool {
+	for _, c := range []string{concat, ""} {
+		for h := 0; h+len(c) <= len(out); h++ {
+			head, middle, tail := out[:h], out[h:h+len(c)], out[h+len(c):]
+			if middle != c || !strings.HasPrefix(str, head) || !strings.HasSuffix(str, tail) {
+				continue
+			}
+			if (mode == KeepHead && tail != "") || (mode == KeepTail && head != "") {
+				continue
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestTruncateBytesProperties(t *testing.T) {
+	for _, mode := range truncateModes {
+		mode := mode
+		property := func(str string, length int8, concatIndex uint8) bool {
+			concat := truncateConcats[int(concatIndex)%len(truncateConcats)]
+			out := TruncateBytes(str, int(length), concat, mode)
+
+			if len(str) <= int(length) {
+				return out == str
+			}
+
+			limit := int(length)
+			if limit < 0 {
+				limit = 0
+			}
+			if len(concat) > limit {
+				concat = ""
+			}
+
+			return len(out) <= limit &&
+				utf8.ValidString(out) &&
+				truncated(str, out, concat, mode) &&
+				// at most one partial UTF-8 sequence is dropped from the head and the tail
+				len(out) > limit-2*utf8.UTFMax
+		}
+
+		if err := quick.Check(property, nil); err != nil {
+			t.Errorf("mode %v: %v", mode, err)
+		}
+	}
+}
+
+func TestTruncateRunesProperties(t *testing.T) {
+	for _, mode := range truncateModes {
+		mode := mode
+		property := func(str string, length int8, concatIndex uint8) bool {
+			concat := truncateConcats[int(concatIndex)%len(truncateConcats)]
+			out := TruncateRunes(str, int(length), concat, mode)
+
+			runeCount := utf8.RuneCountInString(str)
+			if runeCount <= int(length) {
+				return out == str
+			}
+
+			limit := int(length)
+			if limit < 0 {
+				limit = 0
+			}
+			if utf8.RuneCountInString(concat) > limit {
+				concat = ""
+			}
+
+			return utf8.RuneCountInString(out) == limit &&
+				utf8.ValidString(out) &&
+				truncated(str, out, concat, mode)
+		}
+
+		if err := quick.Check(property, nil); err != nil {
+			t.Errorf("mode %v: %v", mode, err)
+		}
+	}
+}
+
+func TestTruncateShortLengths(t *testing.T) {
+	tests := []struct {
+		str    string
+		length int
+		concat string
+		mode   TruncateMode
+		want   string
+	}{
+		{str: "abcdef", length: -1, concat: "...", mode: KeepHeadAndTail, want: ""},
+		{str: "abcdef", length: 0, concat: "...", mode: KeepHead, want: ""},
+		{str: "abcdef", length: 2, concat: "...", mode: KeepHeadAndTail, want: "af"},
+		{str: "abcdef", length: 4, concat: "...", mode: KeepHeadAndTail, want: "a..."},
+		{str: "abcdef", length: 4, concat: "...", mode: KeepHead, want: "a..."},
+		{str: "abcdef", length: 4, concat: "...", mode: KeepTail, want: "...f"},
+		{str: "ééé", length: 3, concat: "", mode: KeepHead, want: "é"},
+		{str: "ééé", length: 3, concat: "", mode: KeepTail, want: "é"},
+		{str: "ab", length: 5, concat: "...", mode: KeepHeadAndTail, want: "ab"},
+	}
+
+	for _, test := range tests {
+		if got := TruncateBytes(test.str, test.length, test.concat, test.mode); got != test.want {
+			t.Errorf("TruncateBytes(%q, %v, %q, %v) = %q, want %q", test.str, test.length, test.concat, test.mode, got, test.want)
+		}
+	}
+
+	if got := TruncateRunes("ééé", 2, "", KeepHeadAndTail); got != "éé" {
+		t.Errorf("TruncateRunes = %q, want %q", got, "éé")
+	}
+	if got := TruncateRunes("ééé", -5, "…", KeepTail); got != "" {
+		t.Errorf("TruncateRunes = %q, want empty", got)
+	}
+}
@@ -1,7 +1,18 @@
 // Package color adds coloring functionality for TTY output.
 package zaplog
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"go.uber.org/zap/zapcore"
+)
 
 const (
 	Black Color = iota + 30
@@ -14,10 +25,190 @@ const (
 	White
 )
 
+const (
+	// ColorAuto enables the colors if both the standard output and the standard error are terminals.
+	// The NO_COLOR environment variable disables, the FORCE_COLOR environment variable enables the colors.
+	ColorAuto ColorMode = iota
+	// ColorAlways enables the colors.
+	ColorAlways
+	// ColorNever disables the colors.
+	ColorNever
+)
+
+// Colorizer adds coloring to a text.
+type Colorizer interface {
+	Add(s string) string
+}
+
 // Color represents a text color.
 type Color uint8
 
+// Color256 represents a color of the 256 color palette.
+type Color256 uint8
+
+// RGBColor represents a 24-bit (truecolor) color.
+type RGBColor struct {
+	R, G, B uint8
+}
+
+// ColorMode decides whether the dev encoder output is colored, see Colors.
+type ColorMode int
+
+// Theme sets the colors of the dev encoder output parts, nil Colorizers leave the parts uncolored.
+type Theme struct {
+	Levels  map[zapcore.Level]Colorizer
+	Caller  Colorizer
+	Logger  Colorizer
+	Message Colorizer
+	Key     Colorizer
+	String  Colorizer
+	// Number colors the numbers, the booleans and null.
+	Number Colorizer
+	Stack  Colorizer
+}
+
+// DefaultTheme colors the levels and the field keys.
+var DefaultTheme = Theme{
+	Levels: map[zapcore.Level]Colorizer{
+		zapcore.DebugLevel:  Magenta,
+		zapcore.InfoLevel:   Blue,
+		zapcore.WarnLevel:   Yellow,
+		zapcore.ErrorLevel:  Red,
+		zapcore.DPanicLevel: Red,
+		zapcore.PanicLevel:  Red,
+		zapcore.FatalLevel:  Red,
+	},
+	Key: Cyan,
+}
+
 // Add adds the coloring to the given string.
 func (c Color) Add(s string) string {
 	return fmt.Sprintf("\x1b[%dm%s\x1b[0m", uint8(c), s)
 }
+
+// Add adds the coloring to the given string.
+func (c Color256) Add(s string) string {
+	return fmt.Sprintf("\x1b[38;5;%dm%s\x1b[0m", uint8(c), s)
+}
+
+// Add adds the coloring to the given string.
+func (c RGBColor) Add(s string) string {
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm%s\x1b[0m", c.R, c.G, c.B, s)
+}
+
+// colorEnabled decides whether the output is colored in mode, see ColorAuto.
+func colorEnabled(mode ColorMode) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := os.Getenv("FORCE_COLOR"); force != "" {
+		return force != "0" && !strings.EqualFold(force, "false")
+	}
+
+	return isTerminal(os.Stdout) && isTerminal(os.Stderr)
+}
+
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// colorize adds the coloring of c to s, if c is not nil.
+func colorize(c Colorizer, s string) string {
+	if c == nil || s == "" {
+		return s
+	}
+
+	return c.Add(s)
+}
+
+// colorJSON reformats the JSON b with the key and value colors of the theme, with the same layout as json.Marshal
+// or json.MarshalIndent with two spaces.
+func colorJSON(b []byte, indent bool, theme Theme) ([]byte, error) {
+	type container struct {
+		object bool
+		empty  bool
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	out := new(bytes.Buffer)
+	var stack []container
+	expectKey := false
+	newline := func() {
+		if indent {
+			out.WriteByte('\n')
+			out.WriteString(strings.Repeat("  ", len(stack)))
+		}
+	}
+
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if delim, ok := t.(json.Delim); ok && (delim == '}' || delim == ']') {
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !c.empty {
+				newline()
+			}
+			out.WriteByte(byte(delim))
+			expectKey = len(stack) > 0 && stack[len(stack)-1].object
+			continue
+		}
+
+		// separator and indentation before the keys and the array elements
+		if len(stack) > 0 {
+			c := &stack[len(stack)-1]
+			if !c.object || expectKey {
+				if !c.empty {
+					out.WriteByte(',')
+				}
+				c.empty = false
+				newline()
+			}
+		}
+
+		if expectKey {
+			key, _ := json.Marshal(t)
+			out.WriteString(colorize(theme.Key, string(key)))
+			out.WriteByte(':')
+			if indent {
+				out.WriteByte(' ')
+			}
+			expectKey = false
+			continue
+		}
+
+		switch v := t.(type) {
+		case json.Delim:
+			out.WriteByte(byte(v))
+			stack = append(stack, container{object: v == '{', empty: true})
+			expectKey = v == '{'
+			continue
+		case string:
+			s, _ := json.Marshal(v)
+			out.WriteString(colorize(theme.String, string(s)))
+		case json.Number:
+			out.WriteString(colorize(theme.Number, v.String()))
+		case bool:
+			out.WriteString(colorize(theme.Number, strconv.FormatBool(v)))
+		case nil:
+			out.WriteString(colorize(theme.Number, "null"))
+		}
+
+		expectKey = len(stack) > 0 && stack[len(stack)-1].object
+	}
+}
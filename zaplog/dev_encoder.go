@@ -1,8 +1,12 @@
 package zaplog
 
 import (
-	"encoding/json"
-	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -18,52 +22,73 @@ type DevEncoderOptions struct {
 	timeLayout    string
 	indentFields  bool
 	excludeFilter map[string]struct{}
+	relativePaths bool
+	moduleRoot    string
+	hyperlinks    bool
+	filterRules   []string
+	filterEnv     string
+	colorMode     ColorMode
+	theme         Theme
+	// colored is decided by the constructor, based on colorMode
+	colored bool
 }
 
 type devEncoder struct {
 	*zapcore.MapObjectEncoder
 	options DevEncoderOptions
-	pool    buffer.Pool
+	filter  *devFilter
+	levels  map[zapcore.Level]string
 }
 
-var (
-	levelToColor = map[zapcore.Level]Color{
-		zapcore.DebugLevel:  Magenta,
-		zapcore.InfoLevel:   Blue,
-		zapcore.WarnLevel:   Yellow,
-		zapcore.ErrorLevel:  Red,
-		zapcore.DPanicLevel: Red,
-		zapcore.PanicLevel:  Red,
-		zapcore.FatalLevel:  Red,
-	}
-	unknownLevelColor = Red
-
-	levelToCapitalColorString = make(map[zapcore.Level]string, len(levelToColor))
-)
-
-func init() {
-	for level, color := range levelToColor {
-		levelToCapitalColorString[level] = color.Add(level.CapitalString())
-	}
-}
+var unknownLevelColor = Red
 
 // NewDevelopmentEncoder create a new zapcore.Encoder configured for development.
+// The caller (see zap.AddCaller) is printed after the level as file:line function, the stack traces of the errors,
+// of the log call site (see CaptureStack) or of zap (see zap.AddStacktrace) are printed after the fields.
+// Each error field with stack traces gets its own section with the causes of the error, the errors joined by
+// errors.Join or multierr are printed as separate sections, the frames shared by the stack traces are printed only once.
+// The entries can be filtered by the FilterEnv environment variable, see FilterRules.
+// The output is colored if it is written to a terminal, see Colors and ColorTheme.
+// The field values which can't be encoded to JSON (eg. channels, NaN, cyclic maps) are printed as !UNENCODABLE(...)
+// or !CYCLE(...) markers, the problems are listed in the EncodingErrorKey field.
 func NewDevelopmentEncoder(options ...DevEncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
 	return func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
 		do := DevEncoderOptions{
 			timeLayout:    "15:04:05.999999",
 			indentFields:  true,
 			excludeFilter: make(map[string]struct{}),
+			filterEnv:     FilterEnv,
+			theme:         DefaultTheme,
 		}
 
 		for _, option := range options {
 			option(&do)
 		}
 
+		if do.relativePaths && do.moduleRoot == "" {
+			do.moduleRoot = moduleRoot()
+		}
+
+		rules := append([]string(nil), do.filterRules...)
+		if env := os.Getenv(do.filterEnv); do.filterEnv != "" && env != "" {
+			rules = append(rules, env)
+		}
+		filter, err := parseDevFilter(rules...)
+		if err != nil {
+			return nil, err
+		}
+
+		do.colored = colorEnabled(do.colorMode)
+		levels := make(map[zapcore.Level]string, len(do.theme.Levels))
+		for level := zapcore.DebugLevel; level <= zapcore.FatalLevel; level++ {
+			levels[level] = do.colorize(do.theme.Levels[level], level.CapitalString())
+		}
+
 		return &devEncoder{
 			MapObjectEncoder: zapcore.NewMapObjectEncoder(),
 			options:          do,
-			pool:             buffer.NewPool(),
+			filter:           filter,
+			levels:           levels,
 		}, nil
 	}
 }
@@ -79,12 +104,13 @@ func (de *devEncoder) Clone() zapcore.Encoder {
 	return &devEncoder{
 		MapObjectEncoder: enc,
 		options:          de.options,
-		pool:             buffer.NewPool(),
+		filter:           de.filter,
+		levels:           de.levels,
 	}
 }
 
 func (de *devEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
-	buf := de.pool.Get()
+	buf := bufferPool.Get()
 
 	for filter := range de.options.excludeFilter {
 		if strings.Contains(entry.Message, filter) {
@@ -102,74 +128,93 @@ func (de *devEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (
 	buf.AppendString(entry.Time.Format(de.options.timeLayout))
 	buf.AppendString(" ")
 
-	level, ok := levelToCapitalColorString[entry.Level]
+	level, ok := de.levels[entry.Level]
 	if !ok {
-		level = unknownLevelColor.Add(entry.Level.CapitalString())
+		level = de.options.colorize(unknownLevelColor, entry.Level.CapitalString())
 	}
 	buf.AppendString(level)
 
 	buf.AppendString(" ")
+	if entry.Caller.Defined {
+		caller := de.options.location(entry.Caller.File, entry.Caller.Line)
+		if fn := runtime.FuncForPC(entry.Caller.PC); fn != nil {
+			caller += " " + shortFunction(fn.Name())
+		}
+		buf.AppendString(de.options.colorize(de.options.theme.Caller, caller))
+		buf.AppendString(" ")
+	}
 	if entry.LoggerName != "" {
-		buf.AppendString(entry.LoggerName)
+		buf.AppendString(de.options.colorize(de.options.theme.Logger, entry.LoggerName))
 		buf.AppendString(" - ")
 	}
 
-	buf.AppendString(entry.Message)
+	buf.AppendString(de.options.colorize(de.options.theme.Message, entry.Message))
 	buf.AppendString("\n")
 
-	errWithStack := ""
+	errs := &errorRenderer{options: de.options}
+	callStack := ""
+	// the messages of the errors printed after the fields, they are matched by the filter rules as fields
+	var errMessages map[string]interface{}
 
 	for _, f := range fields {
-		err, ok := f.Interface.(error)
-		if ok {
-			type stackTracer interface {
-				StackTrace() errors.StackTrace
-			}
-
-			type causer interface {
-				Cause() error
-			}
-
-			for err != nil {
-				_, ok := err.(stackTracer)
-				if ok {
-					errWithStack = fmt.Sprintf("%+v\n", err)
-					// errors package will format the nested errors too with their stack traces
-					// so don't need to check the underlying errors
-					break
-				}
+		if st, ok := f.Interface.(stackTrace); ok && f.Key == StacktraceKey {
+			callStack = de.options.formatStack(framesOf(errors.StackTrace(st), 0))
+			continue
+		}
 
-				cause, ok := err.(causer)
-				if !ok {
-					break
+		// the errors with stack traces are printed after the fields, the others are kept in the fields
+		if err, ok := f.Interface.(error); ok && errorTreeHasStackTrace(err, 0) {
+			errs.render(f.Key, err, 0)
+			if de.filter != nil {
+				if errMessages == nil {
+					errMessages = make(map[string]interface{})
 				}
-				err = cause.Cause()
-			}
-
-			// if it wasn't a stackTracer, just add the error message to the fields
-			if errWithStack == "" {
-				f.AddTo(enc)
+				errMessages[f.Key] = err.Error()
 			}
-		} else {
-			f.AddTo(enc)
+			continue
 		}
+
+		addField(enc, f)
 	}
 
-	var b []byte
-	var err error
-	if de.options.indentFields {
-		b, err = json.MarshalIndent(enc.Fields, "", "  ")
-	} else {
-		b, err = json.Marshal(enc.Fields)
+	filterFields := enc.Fields
+	if len(errMessages) > 0 {
+		filterFields = make(map[string]interface{}, len(enc.Fields)+len(errMessages))
+		for k, v := range enc.Fields {
+			filterFields[k] = v
+		}
+		for k, v := range errMessages {
+			filterFields[k] = v
+		}
+	}
+	if !de.filter.match(entry, filterFields) {
+		buf.Reset()
+		return buf, nil
 	}
 
+	b, err := marshalJSON(enc.Fields, de.options.indentFields)
+	if err != nil {
+		// the unencodable values (eg. channels, NaN, cyclic maps) are replaced by markers, see sanitizeFields
+		b, err = marshalJSON(sanitizeFields(enc.Fields), de.options.indentFields)
+	}
 	if err != nil {
-		panic(err)
+		b = []byte(strconv.Quote(EncodingErrorKey + ": " + err.Error()))
+	}
+
+	if de.options.colored && (de.options.theme.Key != nil || de.options.theme.String != nil || de.options.theme.Number != nil) {
+		if colored, err := colorJSON(b, de.options.indentFields, de.options.theme); err == nil {
+			b = colored
+		}
+	}
+
+	if callStack == "" && entry.Stack != "" {
+		callStack = de.options.formatStack(parseStack(entry.Stack, 0))
 	}
 
 	_, _ = buf.Write(b)
 	buf.AppendString("\n")
-	buf.AppendString(errWithStack)
+	buf.AppendString(de.options.colorizeBlock(de.options.theme.Stack, errs.String()))
+	buf.AppendString(de.options.colorizeBlock(de.options.theme.Stack, callStack))
 
 	return buf, nil
 }
@@ -186,6 +231,7 @@ func IndentFields(indent bool) DevEncoderOption {
 	}
 }
 
+// ExcludeFilter drops the entries with a message containing any of the filters, see FilterRules for more complex rules.
 func ExcludeFilter(filters ...string) DevEncoderOption {
 	return func(de *DevEncoderOptions) {
 		for _, f := range filters {
@@ -193,3 +239,123 @@ func ExcludeFilter(filters ...string) DevEncoderOption {
 		}
 	}
 }
+
+// RelativePaths makes the caller and the stack trace file paths relative to the module root,
+// which is the closest directory containing a go.mod file, starting from the working directory. See ModuleRoot.
+func RelativePaths(enabled bool) DevEncoderOption {
+	return func(de *DevEncoderOptions) {
+		de.relativePaths = enabled
+	}
+}
+
+// ModuleRoot sets the directory the file paths are made relative to, see RelativePaths.
+func ModuleRoot(dir string) DevEncoderOption {
+	return func(de *DevEncoderOptions) {
+		de.relativePaths = true
+		de.moduleRoot = dir
+	}
+}
+
+// Hyperlinks makes the caller and the stack trace file paths OSC 8 terminal hyperlinks pointing to the files.
+func Hyperlinks(enabled bool) DevEncoderOption {
+	return func(de *DevEncoderOptions) {
+		de.hyperlinks = enabled
+	}
+}
+
+// Colors sets whether the output is colored, ColorAuto by default.
+func Colors(mode ColorMode) DevEncoderOption {
+	return func(de *DevEncoderOptions) {
+		de.colorMode = mode
+	}
+}
+
+// ColorTheme sets the colors of the output, DefaultTheme by default.
+func ColorTheme(theme Theme) DevEncoderOption {
+	return func(de *DevEncoderOptions) {
+		de.theme = theme
+	}
+}
+
+// colorize adds the coloring of c to s, if the colors are enabled.
+func (do DevEncoderOptions) colorize(c Colorizer, s string) string {
+	if !do.colored {
+		return s
+	}
+
+	return colorize(c, s)
+}
+
+// colorizeBlock colorizes a block of lines ending with a line ending, the line ending is not colored.
+func (do DevEncoderOptions) colorizeBlock(c Colorizer, s string) string {
+	if !strings.HasSuffix(s, "\n") {
+		return do.colorize(c, s)
+	}
+
+	return do.colorize(c, strings.TrimSuffix(s, "\n")) + "\n"
+}
+
+// location formats the file path and the line, relative to the module root and as a hyperlink, if they are enabled.
+func (do DevEncoderOptions) location(file string, line int) string {
+	text := file
+	if do.relativePaths && do.moduleRoot != "" {
+		text = strings.TrimPrefix(file, do.moduleRoot+"/")
+	}
+	text += ":" + strconv.Itoa(line)
+
+	if !do.hyperlinks || !path.IsAbs(file) {
+		return text
+	}
+
+	return "\x1b]8;;file://" + (&url.URL{Path: file}).EscapedPath() + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+func (do DevEncoderOptions) formatStack(frames stackFrames) string {
+	if len(frames) == 0 {
+		return ""
+	}
+
+	b := new(strings.Builder)
+	b.WriteString("Stack trace:\n")
+	do.writeFrames(b, frames)
+
+	return b.String()
+}
+
+func (do DevEncoderOptions) writeFrames(b *strings.Builder, frames stackFrames) {
+	for _, f := range frames {
+		b.WriteString(f.function)
+		b.WriteString("\n\t")
+		b.WriteString(do.location(f.file, f.line))
+		b.WriteString("\n")
+	}
+}
+
+// shortFunction strips the package path from a function name, eg. github.com/a/b.(*T).F becomes b.(*T).F.
+func shortFunction(function string) string {
+	if i := strings.LastIndex(function, "/"); i >= 0 {
+		return function[i+1:]
+	}
+
+	return function
+}
+
+// moduleRoot returns the closest directory containing a go.mod file, starting from the working directory.
+func moduleRoot() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return filepath.ToSlash(dir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
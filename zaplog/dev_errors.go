@@ -0,0 +1,141 @@
+package zaplog
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// errorRenderer renders the error fields with stack traces for the dev encoder. Each error field gets its own section:
+// the error message, the compact list of its causes and its stack traces. The stack trace of the deepest error is printed
+// in full, the frames shared with the already printed stack traces are omitted from the others.
+type errorRenderer struct {
+	options   DevEncoderOptions
+	b         strings.Builder
+	printed   []stackFrames
+	sections  int
+	truncated bool
+}
+
+func (er *errorRenderer) String() string {
+	return er.b.String()
+}
+
+// render adds the section of err, the errors joined by err are rendered as separate sections labelled <label>[i].
+// The sections are not rendered beyond maxErrorDepth or maxErrorNodes, a truncation marker is written instead once.
+func (er *errorRenderer) render(label string, err error, depth int) {
+	if depth >= maxErrorDepth || er.sections >= maxErrorNodes {
+		er.writeTruncated(label)
+		return
+	}
+	er.sections++
+
+	var messages []string
+	var stacks []errors.StackTrace
+	var joined []error
+	for e := err; e != nil && depth < maxErrorDepth; depth++ {
+		if msg := e.Error(); len(messages) == 0 || messages[len(messages)-1] != msg {
+			messages = append(messages, msg)
+		}
+		if st, ok := e.(stackTracer); ok {
+			stacks = append(stacks, st.StackTrace())
+		}
+
+		var cause error
+		cause, joined = unwrapError(e)
+		if joined != nil {
+			break
+		}
+		e = cause
+	}
+
+	if len(messages) == 0 {
+		er.writeTruncated(label)
+		return
+	}
+
+	er.b.WriteString("Error ")
+	er.b.WriteString(label)
+	er.b.WriteString(": ")
+	er.writeMessage(messages[0])
+
+	// the messages of the wrapped errors usually end with the messages of their causes, eg. "a: b: c", "b: c", "c"
+	if len(messages) > 1 {
+		er.b.WriteString("Causes:\n")
+		for i, msg := range messages {
+			if i+1 < len(messages) && strings.HasSuffix(msg, ": "+messages[i+1]) {
+				msg = strings.TrimSuffix(msg, ": "+messages[i+1])
+			}
+			er.b.WriteString("\t")
+			er.writeMessage(msg)
+		}
+	}
+
+	header := "Stack trace:\n"
+	for i := len(stacks) - 1; i >= 0; i-- {
+		if er.writeStack(header, framesOf(stacks[i], 0)) {
+			header = "Wrapped at:\n"
+		}
+	}
+
+	// the joined errors without stack traces are printed only in the message of err
+	for i, e := range joined {
+		if e != nil && errorTreeHasStackTrace(e, 0) {
+			er.render(label+"["+strconv.Itoa(i)+"]", e, depth+1)
+		}
+	}
+}
+
+// writeTruncated writes the truncation marker of the errors exceeding the rendering limits, only for the first such error.
+func (er *errorRenderer) writeTruncated(label string) {
+	if er.truncated {
+		return
+	}
+	er.truncated = true
+
+	er.b.WriteString("Error ")
+	er.b.WriteString(label)
+	er.b.WriteString(": !TRUNCATED(max depth)\n")
+}
+
+// writeMessage writes msg, the lines of multiline messages (eg. of errors.Join) are indented.
+func (er *errorRenderer) writeMessage(msg string) {
+	er.b.WriteString(strings.Replace(msg, "\n", "\n\t", -1))
+	er.b.WriteString("\n")
+}
+
+// writeStack writes the frames which are not shared with the already printed stack traces,
+// returns false if all of them were printed already.
+func (er *errorRenderer) writeStack(header string, frames stackFrames) bool {
+	common := 0
+	for _, printed := range er.printed {
+		if n := commonSuffix(frames, printed); n > common {
+			common = n
+		}
+	}
+	if len(frames) == 0 || common == len(frames) {
+		return false
+	}
+	er.printed = append(er.printed, frames)
+
+	er.b.WriteString(header)
+	er.options.writeFrames(&er.b, frames[:len(frames)-common])
+	if common > 0 {
+		er.b.WriteString("\t... ")
+		er.b.WriteString(strconv.Itoa(common))
+		er.b.WriteString(" frames in common\n")
+	}
+
+	return true
+}
+
+// commonSuffix returns the number of the common frames at the end (bottom) of the stacks.
+func commonSuffix(a, b stackFrames) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,98 @@
+package zaplog
+
+import (
+	stderrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// cyclicError joins itself, its message does not include the joined errors.
+type cyclicError struct {
+	errs []error
+}
+
+func (e *cyclicError) Error() string {
+	return "cycle"
+}
+
+func (e *cyclicError) Unwrap() []error {
+	return e.errs
+}
+
+func TestDevEncoderErrorLimits(t *testing.T) {
+	deep := error(errors.New("deepest"))
+	for i := 0; i < 70; i++ {
+		deep = stderrors.Join(stderrors.New("plain"), deep)
+	}
+	deepWithStack := stderrors.Join(errors.New("x"), deep)
+
+	cycle := &cyclicError{}
+	cycle.errs = []error{errors.New("boom"), cycle}
+	branchingCycle := &cyclicError{}
+	branchingCycle.errs = []error{branchingCycle, branchingCycle, errors.New("boom")}
+
+	tests := []struct {
+		name      string
+		err       error
+		truncated bool
+		stack     bool
+	}{
+		// the stack trace is beyond the maximum depth, the error is printed as a field
+		{name: "deep join", err: deep},
+		{name: "deep join with a stack trace on top", err: deepWithStack, stack: true},
+		{name: "cyclic join", err: cycle, truncated: true, stack: true},
+		{name: "branching cyclic join", err: branchingCycle, truncated: true, stack: true},
+		{name: "shallow join", err: stderrors.Join(stderrors.New("a"), errors.New("b")), stack: true},
+	}
+
+	enc, err := NewDevelopmentEncoder(FilterEnvVar(""), Colors(ColorNever))(zap.NewDevelopmentEncoderConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		start := time.Now()
+		buf, err := enc.EncodeEntry(testEntry(zapcore.ErrorLevel, "failed"), []zapcore.Field{zap.Error(test.err)})
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("%v: rendering took %v", test.name, d)
+		}
+
+		out := buf.String()
+		if truncated := strings.Contains(out, "!TRUNCATED(max depth)"); truncated != test.truncated {
+			t.Errorf("%v: expected truncated %v, got %v", test.name, test.truncated, out)
+		}
+		if strings.Count(out, "!TRUNCATED") > 1 {
+			t.Errorf("%v: the truncation marker is written more than once", test.name)
+		}
+		if stack := strings.Contains(out, "Stack trace:"); stack != test.stack {
+			t.Errorf("%v: expected stack trace %v, got %v", test.name, test.stack, out)
+		}
+	}
+}
+
+func TestDevEncoderJoinedErrorsWithoutStackTrace(t *testing.T) {
+	enc, err := NewDevelopmentEncoder(FilterEnvVar(""), Colors(ColorNever))(zap.NewDevelopmentEncoderConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := enc.EncodeEntry(testEntry(zapcore.ErrorLevel, "failed"), []zapcore.Field{
+		zap.Error(stderrors.Join(stderrors.New("plain"), errors.New("with stack"))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "Error error[0]") || !strings.Contains(out, "Error error[1]: with stack") {
+		t.Errorf("expected a section only for the joined error with a stack trace: %v", out)
+	}
+}
@@ -0,0 +1,365 @@
+package zaplog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+// FilterEnv is the default environment variable of the dev encoder filter rules, see FilterRules.
+const FilterEnv = "DLIVER_LOG_FILTER"
+
+// devFilter decides which entries are printed by the dev encoder, see FilterRules.
+type devFilter struct {
+	rules []filterRule
+}
+
+type filterRule struct {
+	include bool
+	// the terms of the inner slices are joined by AND, the inner slices by OR
+	terms [][]filterTerm
+}
+
+type filterTerm struct {
+	negate  bool
+	subject string
+	key     string
+	op      string
+	value   string
+	level   zapcore.Level
+	re      *regexp.Regexp
+}
+
+type filterParser struct {
+	expr string
+	pos  int
+}
+
+// FilterRules adds filter rules to the dev encoder, the rules of the FilterEnv environment variable are added automatically.
+// The rules are separated by ';', each starts with '+' (include) or '-' (exclude), followed by conditions joined by
+// '&' (AND) and '|' (OR), AND binds stronger. A condition can be negated by '!'. The conditions are:
+//
+//	level=warn, level!=warn, level>=warn, level>warn, level<=warn, level<warn
+//	logger=name, logger!=name, logger~regexp, logger!~regexp
+//	msg=message, msg!=message, msg~regexp, msg!~regexp
+//	field:key (the field is present), field:key=value, field:key!=value, field:key~regexp, field:key!~regexp
+//
+// The error fields are matched by their messages, including the errors printed with their stack traces after the fields.
+// Values containing spaces or any of ";&|" must be double quoted (Go string syntax). Entries matching any exclude rule
+// are dropped, if there are include rules, only the entries matching at least one of them are printed. For example:
+//
+//	-logger=db & level<warn; -msg~"^health check"; +level>=info | field:correlation_id=abc
+//
+// Invalid rules make NewDevelopmentEncoder return an error.
+func FilterRules(rules string) DevEncoderOption {
+	return func(de *DevEncoderOptions) {
+		de.filterRules = append(de.filterRules, rules)
+	}
+}
+
+// FilterEnvVar sets the environment variable of the filter rules (FilterEnv by default), an empty name disables it.
+func FilterEnvVar(name string) DevEncoderOption {
+	return func(de *DevEncoderOptions) {
+		de.filterEnv = name
+	}
+}
+
+func parseDevFilter(exprs ...string) (*devFilter, error) {
+	f := &devFilter{}
+	for _, expr := range exprs {
+		p := &filterParser{expr: expr}
+		rules, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		f.rules = append(f.rules, rules...)
+	}
+
+	if len(f.rules) == 0 {
+		return nil, nil
+	}
+
+	return f, nil
+}
+
+// match checks whether the entry with the fields should be printed.
+func (f *devFilter) match(entry zapcore.Entry, fields map[string]interface{}) bool {
+	if f == nil {
+		return true
+	}
+
+	included, hasInclude := false, false
+	for _, r := range f.rules {
+		if r.include {
+			hasInclude = true
+			if !included && r.match(entry, fields) {
+				included = true
+			}
+			continue
+		}
+
+		if r.match(entry, fields) {
+			return false
+		}
+	}
+
+	return included || !hasInclude
+}
+
+func (r filterRule) match(entry zapcore.Entry, fields map[string]interface{}) bool {
+	for _, and := range r.terms {
+		matched := true
+		for _, t := range and {
+			if t.match(entry, fields) == t.negate {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t filterTerm) match(entry zapcore.Entry, fields map[string]interface{}) bool {
+	var actual string
+	switch t.subject {
+	case "level":
+		switch t.op {
+		case "=":
+			return entry.Level == t.level
+		case "!=":
+			return entry.Level != t.level
+		case ">=":
+			return entry.Level >= t.level
+		case ">":
+			return entry.Level > t.level
+		case "<=":
+			return entry.Level <= t.level
+		default:
+			return entry.Level < t.level
+		}
+	case "logger":
+		actual = entry.LoggerName
+	case "msg":
+		actual = entry.Message
+	default:
+		v, ok := fields[t.key]
+		if t.op == "" || !ok {
+			return ok
+		}
+		actual = fmt.Sprint(v)
+	}
+
+	switch t.op {
+	case "=":
+		return actual == t.value
+	case "!=":
+		return actual != t.value
+	case "~":
+		return t.re.MatchString(actual)
+	default:
+		return !t.re.MatchString(actual)
+	}
+}
+
+func (p *filterParser) parse() ([]filterRule, error) {
+	var rules []filterRule
+	for {
+		p.skipSpace()
+		if p.pos == len(p.expr) {
+			return rules, nil
+		}
+		if p.expr[p.pos] == ';' {
+			p.pos++
+			continue
+		}
+
+		rule, err := p.rule()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+
+		p.skipSpace()
+		if p.pos < len(p.expr) && p.expr[p.pos] != ';' {
+			return nil, p.errorf("expected ';'")
+		}
+	}
+}
+
+func (p *filterParser) rule() (filterRule, error) {
+	var r filterRule
+	switch p.expr[p.pos] {
+	case '+':
+		r.include = true
+	case '-':
+	default:
+		return r, p.errorf("expected '+' or '-'")
+	}
+	p.pos++
+
+	for {
+		and, err := p.and()
+		if err != nil {
+			return r, err
+		}
+		r.terms = append(r.terms, and)
+
+		p.skipSpace()
+		if p.pos == len(p.expr) || p.expr[p.pos] != '|' {
+			return r, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *filterParser) and() ([]filterTerm, error) {
+	var terms []filterTerm
+	for {
+		t, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+
+		p.skipSpace()
+		if p.pos == len(p.expr) || p.expr[p.pos] != '&' {
+			return terms, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *filterParser) term() (filterTerm, error) {
+	var t filterTerm
+
+	p.skipSpace()
+	if p.pos < len(p.expr) && p.expr[p.pos] == '!' {
+		t.negate = true
+		p.pos++
+		p.skipSpace()
+	}
+
+	start := p.pos
+	switch {
+	case strings.HasPrefix(p.expr[p.pos:], "field:"):
+		p.pos += len("field:")
+		t.subject = "field"
+		t.key = p.word()
+		if t.key == "" {
+			return t, p.errorf("missing field key")
+		}
+	default:
+		t.subject = p.word()
+		if t.subject != "level" && t.subject != "logger" && t.subject != "msg" {
+			p.pos = start
+			return t, p.errorf("expected level, logger, msg or field:<key>")
+		}
+	}
+
+	t.op = p.operator()
+	if t.op == "" {
+		if t.subject != "field" {
+			return t, p.errorf("missing operator")
+		}
+		return t, nil
+	}
+
+	value, err := p.value()
+	if err != nil {
+		return t, err
+	}
+	t.value = value
+
+	switch {
+	case t.subject == "level":
+		if t.op == "~" || t.op == "!~" {
+			return t, p.errorf("regular expressions are not supported for the level")
+		}
+		if err := t.level.UnmarshalText([]byte(strings.ToLower(value))); err != nil {
+			return t, p.errorf("invalid level %q", value)
+		}
+	case t.op == ">=" || t.op == ">" || t.op == "<=" || t.op == "<":
+		return t, p.errorf("operator %v is supported only for the level", t.op)
+	case t.op == "~" || t.op == "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return t, p.errorf("invalid regular expression %q", value)
+		}
+		t.re = re
+	}
+
+	return t, nil
+}
+
+// word reads a subject or a field key.
+func (p *filterParser) word() string {
+	start := p.pos
+	for p.pos < len(p.expr) && !strings.ContainsRune(" \t\n;&|!=~<>\"", rune(p.expr[p.pos])) {
+		p.pos++
+	}
+
+	return p.expr[start:p.pos]
+}
+
+func (p *filterParser) operator() string {
+	p.skipSpace()
+	for _, op := range []string{"!=", "!~", ">=", "<=", "=", "~", ">", "<"} {
+		if strings.HasPrefix(p.expr[p.pos:], op) {
+			p.pos += len(op)
+			return op
+		}
+	}
+
+	return ""
+}
+
+func (p *filterParser) value() (string, error) {
+	p.skipSpace()
+	if p.pos < len(p.expr) && p.expr[p.pos] == '"' {
+		start := p.pos
+		for p.pos++; p.pos < len(p.expr); p.pos++ {
+			switch p.expr[p.pos] {
+			case '\\':
+				p.pos++
+			case '"':
+				p.pos++
+				v, err := strconv.Unquote(p.expr[start:p.pos])
+				if err != nil {
+					p.pos = start
+					return "", p.errorf("invalid quoted value")
+				}
+				return v, nil
+			}
+		}
+		p.pos = start
+		return "", p.errorf("unterminated quoted value")
+	}
+
+	start := p.pos
+	for p.pos < len(p.expr) && !strings.ContainsRune(" \t\n;&|", rune(p.expr[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("missing value")
+	}
+
+	return p.expr[start:p.pos], nil
+}
+
+func (p *filterParser) skipSpace() {
+	for p.pos < len(p.expr) && strings.ContainsRune(" \t\n", rune(p.expr[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("zaplog: invalid filter %q at position %v: %v", p.expr, p.pos, fmt.Sprintf(format, args...))
+}
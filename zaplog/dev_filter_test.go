@@ -0,0 +1,159 @@
+package zaplog
+
+import (
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseDevFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr   string
+		reason string
+	}{
+		{expr: "level=info", reason: "expected '+' or '-'"},
+		{expr: "+", reason: "expected level, logger, msg or field:<key>"},
+		{expr: "+caller=x", reason: "expected level, logger, msg or field:<key>"},
+		{expr: "+field:", reason: "missing field key"},
+		{expr: "+level", reason: "missing operator"},
+		{expr: "+logger=", reason: "missing value"},
+		{expr: "+level=loud", reason: `invalid level "loud"`},
+		{expr: "+level~warn", reason: "regular expressions are not supported for the level"},
+		{expr: "+msg>=a", reason: "operator >= is supported only for the level"},
+		{expr: "+msg~(", reason: `invalid regular expression "("`},
+		{expr: "+msg~^(a|b)$", reason: `invalid regular expression "^(a"`},
+		{expr: `+msg="abc`, reason: "unterminated quoted value"},
+		{expr: `+msg="\q"`, reason: "invalid quoted value"},
+		{expr: "+msg=a b", reason: "expected ';'"},
+		{expr: "+msg=a &", reason: "expected level, logger, msg or field:<key>"},
+		{expr: "+msg=a | ; -level=info", reason: "expected level, logger, msg or field:<key>"},
+	}
+
+	for _, test := range tests {
+		_, err := parseDevFilter(test.expr)
+		if err == nil {
+			t.Errorf("%q: expected an error", test.expr)
+			continue
+		}
+		if !strings.HasSuffix(err.Error(), ": "+test.reason) {
+			t.Errorf("%q: expected reason %q, got %v", test.expr, test.reason, err)
+		}
+	}
+}
+
+func TestDevFilterMatch(t *testing.T) {
+	fields := map[string]interface{}{"correlation_id": "abc", "count": 3, "path": "/a;b c"}
+
+	tests := []struct {
+		name   string
+		exprs  []string
+		level  zapcore.Level
+		logger string
+		msg    string
+		want   bool
+	}{
+		{name: "no rules", want: true},
+		{name: "empty rules", exprs: []string{" ; ;"}, want: true},
+		{name: "exclude level", exprs: []string{"-level<warn"}, level: zapcore.InfoLevel, want: false},
+		{name: "exclude level not matching", exprs: []string{"-level<warn"}, level: zapcore.WarnLevel, want: true},
+		{name: "level names are case insensitive", exprs: []string{"-level=WARN"}, level: zapcore.WarnLevel, want: false},
+		{name: "include not matching", exprs: []string{"+logger=db"}, logger: "http", want: false},
+		{name: "include any", exprs: []string{"+logger=db; +logger=http"}, logger: "http", want: true},
+		{name: "exclude wins over include", exprs: []string{"+logger=db; -msg~^ping"}, logger: "db", msg: "ping", want: false},
+		{name: "AND binds stronger than OR", exprs: []string{"-logger=db & level=info | msg=x"}, logger: "http", msg: "x", want: false},
+		{name: "AND of the first alternative", exprs: []string{"-logger=db & level=info | msg=x"}, logger: "db", level: zapcore.WarnLevel, want: true},
+		{name: "AND matching", exprs: []string{"-logger=db & level=info | msg=x"}, logger: "db", want: false},
+		{name: "negated term", exprs: []string{"-!logger=db"}, logger: "http", want: false},
+		{name: "negated term not matching", exprs: []string{"-! logger=db"}, logger: "db", want: true},
+		{name: "negated regexp operator", exprs: []string{`+logger!~"^(db|http)$"`}, logger: "cache", want: true},
+		{name: "field presence", exprs: []string{"+field:correlation_id"}, want: true},
+		{name: "missing field", exprs: []string{"+field:token"}, want: false},
+		{name: "field value", exprs: []string{"-field:count=3"}, want: false},
+		{name: "field value not matching", exprs: []string{"-field:count!=3"}, want: true},
+		{name: "quoted field value", exprs: []string{`-field:path="/a;b c" & msg="a \"b\""`}, msg: `a "b"`, want: false},
+		{name: "quoted regexp", exprs: []string{`+msg~"^health check\\b"`}, msg: "health check ok", want: true},
+		{name: "rules of several expressions", exprs: []string{"+logger=db", "-level=debug"}, logger: "db", level: zapcore.DebugLevel, want: false},
+	}
+
+	for _, test := range tests {
+		f, err := parseDevFilter(test.exprs...)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		entry := zapcore.Entry{Level: test.level, LoggerName: test.logger, Message: test.msg}
+		if got := f.match(entry, fields); got != test.want {
+			t.Errorf("%v: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestDevEncoderFilterRules(t *testing.T) {
+	t.Setenv(FilterEnv, "-logger=db")
+
+	tests := []struct {
+		name    string
+		options []DevEncoderOption
+		logger  string
+		printed bool
+	}{
+		{name: "environment variable", logger: "db", printed: false},
+		{name: "environment variable disabled", options: []DevEncoderOption{FilterEnvVar("")}, logger: "db", printed: true},
+		{name: "option rules", options: []DevEncoderOption{FilterRules("-logger=http")}, logger: "http", printed: false},
+		{name: "option and environment rules", options: []DevEncoderOption{FilterRules("+logger=http")}, logger: "cache", printed: false},
+		{name: "no matching rules", logger: "http", printed: true},
+	}
+
+	for _, test := range tests {
+		enc, err := NewDevelopmentEncoder(append(test.options, Colors(ColorNever))...)(zap.NewDevelopmentEncoderConfig())
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+
+		entry := testEntry(zapcore.InfoLevel, "message")
+		entry.LoggerName = test.logger
+		buf, err := enc.EncodeEntry(entry, nil)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if printed := buf.Len() > 0; printed != test.printed {
+			t.Errorf("%v: expected printed %v, got %q", test.name, test.printed, buf.String())
+		}
+	}
+
+	if _, err := NewDevelopmentEncoder(FilterRules("+level=loud"))(zap.NewDevelopmentEncoderConfig()); err == nil {
+		t.Errorf("expected an error for the invalid rules")
+	}
+}
+
+func TestDevEncoderFilterErrorFields(t *testing.T) {
+	rules := []string{"-field:error", "-field:error=boom", "-field:error~^bo", "-field:error!=other"}
+	errs := map[string]error{
+		"standard error":   stderrors.New("boom"),
+		"pkg/errors error": errors.New("boom"),
+		"wrapped error":    fmt.Errorf("%w", errors.New("boom")),
+	}
+
+	for _, rule := range rules {
+		enc, err := NewDevelopmentEncoder(FilterRules(rule), FilterEnvVar(""), Colors(ColorNever))(zap.NewDevelopmentEncoderConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, e := range errs {
+			buf, err := enc.EncodeEntry(testEntry(zapcore.ErrorLevel, "failed"), []zapcore.Field{zap.Error(e)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() > 0 {
+				t.Errorf("%v %v: expected the entry to be dropped, got %q", rule, name, buf.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package zaplog
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/proemergotech/log/v3"
+)
+
+const (
+	ECSEncoderType = "dliver-ecs"
+	ecsVersion     = "1.12.0"
+)
+
+type ecsEncoder struct {
+	zapcore.Encoder
+	options EncoderOptions
+	mapping map[string]string
+	limiter fieldLimiter
+}
+
+// DefaultECSFieldMapping maps the field keys used by this library to Elastic Common Schema fields.
+var DefaultECSFieldMapping = map[string]string{
+	log.AppName:      "service.name",
+	log.AppVersion:   "service.version",
+	log.ErrorCode:    "error.code",
+	"correlation_id": "trace.id",
+	"workflow_id":    "labels.workflow_id",
+}
+
+// NewECSEncoder create a new zapcore.Encoder writing Elastic Common Schema (ECS) compliant JSON lines.
+// The first error of the fields is written to the error.message, error.type and error.stack_trace fields,
+// the field keys are mapped by DefaultECSFieldMapping and ECSFieldMapping, the other fields are written as they are.
+// The truncation and the size limits are handled the same way as by NewEncoder.
+func NewECSEncoder(options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+	return func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		eo, err := newEncoderOptions(ECSEncoderType, options, optECSFieldMapping)
+		if err != nil {
+			return nil, err
+		}
+
+		mapping := make(map[string]string, len(DefaultECSFieldMapping)+len(eo.ecsFieldMapping))
+		for k, v := range DefaultECSFieldMapping {
+			mapping[k] = v
+		}
+		for k, v := range eo.ecsFieldMapping {
+			if v == "" {
+				delete(mapping, k)
+				continue
+			}
+			mapping[k] = v
+		}
+
+		// copy the struct
+		ecsCfg := cfg
+		ecsCfg.TimeKey = "@timestamp"
+		ecsCfg.LevelKey = "log.level"
+		ecsCfg.NameKey = "log.logger"
+		ecsCfg.MessageKey = "message"
+		ecsCfg.CallerKey = ""
+		ecsCfg.StacktraceKey = ""
+		ecsCfg.LineEnding = "\n"
+		ecsCfg.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(t.UTC().Format(time.RFC3339Nano))
+		}
+		ecsCfg.EncodeLevel = func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(l.String())
+		}
+		if ecsCfg.EncodeDuration == nil {
+			ecsCfg.EncodeDuration = zapcore.NanosDurationEncoder
+		}
+		ecsCfg.EncodeName = nil
+
+		return &ecsEncoder{
+			Encoder: zapcore.NewJSONEncoder(ecsCfg),
+			options: eo,
+			mapping: mapping,
+			limiter: newFieldLimiter(fieldsEncoderConfig(cfg), eo.fieldSizeLimit, eo.entrySizeLimit),
+		}, nil
+	}
+}
+
+// ECSFieldMapping adds field key mappings to DefaultECSFieldMapping for the ECS encoder,
+// mapping a key to an empty string removes the default mapping.
+func ECSFieldMapping(mapping map[string]string) EncoderOption {
+	return func(eo *EncoderOptions) {
+		eo.restrict(optECSFieldMapping)
+		if eo.ecsFieldMapping == nil {
+			eo.ecsFieldMapping = make(map[string]string, len(mapping))
+		}
+		for k, v := range mapping {
+			eo.ecsFieldMapping[k] = v
+		}
+	}
+}
+
+func (ee *ecsEncoder) Clone() zapcore.Encoder {
+	return &ecsEncoder{
+		Encoder: ee.Encoder.Clone(),
+		options: ee.options,
+		mapping: ee.mapping,
+		limiter: ee.limiter,
+	}
+}
+
+// AddString maps the keys of the string fields added to the logger (eg. by zap.Logger.With), the correlation fields are usually strings.
+func (ee *ecsEncoder) AddString(key, value string) {
+	ee.Encoder.AddString(ee.key(key), value)
+}
+
+func (ee *ecsEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	if ee.options.messageTruncateLimit > 0 {
+		entry.Message = log.Truncate(entry.Message, ee.options.messageTruncateLimit, truncateConcat)
+	}
+
+	_, stackEnabled := ee.options.stackTraceLevels[entry.Level]
+
+	ecsFields := make([]zapcore.Field, 0, len(fields)+6)
+	ecsFields = append(ecsFields, zap.String("ecs.version", ecsVersion))
+	if entry.Caller.Defined {
+		ecsFields = append(ecsFields,
+			zap.String("log.origin.file.name", entry.Caller.File),
+			zap.Int("log.origin.file.line", entry.Caller.Line),
+		)
+	}
+
+	var errorFound bool
+	var stack string
+	for _, f := range fields {
+		if st, ok := f.Interface.(stackTrace); ok && f.Key == StacktraceKey {
+			stack = st.String()
+			continue
+		}
+
+		if err, ok := f.Interface.(error); ok && f.Type == zapcore.ErrorType && !errorFound {
+			errorFound = true
+			ecsFields = append(ecsFields,
+				zap.String("error.message", err.Error()),
+				zap.String("error.type", fmt.Sprintf("%T", errors.Cause(err))),
+			)
+			if hasStackTrace(err) {
+				stack = verboseError(err)
+			}
+			continue
+		}
+
+		f.Key = ee.key(f.Key)
+		ecsFields = append(ecsFields, f)
+	}
+
+	if stack == "" {
+		stack = entry.Stack
+	}
+	if stackEnabled && stack != "" {
+		ecsFields = append(ecsFields, zap.String("error.stack_trace", stack))
+	}
+	entry.Stack = ""
+
+	return ee.Encoder.EncodeEntry(entry, ee.limiter.limit(ecsFields, 0))
+}
+
+func (ee *ecsEncoder) key(key string) string {
+	if mapped, ok := ee.mapping[key]; ok {
+		return mapped
+	}
+
+	return key
+}
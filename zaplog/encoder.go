@@ -1,13 +1,18 @@
 package zaplog
 
 import (
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	"go.uber.org/zap/buffer"
 	"go.uber.org/zap/zapcore"
 
 	"github.com/proemergotech/log/v3"
 
+	"net/url"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 const (
@@ -15,88 +20,518 @@ const (
 	messageTruncateLimit = 500
 )
 
+// EncoderOption configures the dliver, logfmt, ECS, GELF, GCP and MessagePack encoders.
+// The constructors return an error if an option not supported by the encoder is passed.
+type EncoderOption func(eo *EncoderOptions)
+
+type EncoderOptions struct {
+	stackTraceDepth      int
+	stackTraceLevels     map[zapcore.Level]struct{}
+	messageTruncateLimit int
+	fieldSizeLimit       int
+	entrySizeLimit       int
+	formatVersion        int
+	ecsFieldMapping      map[string]string
+	gcpProjectID         string
+	gcpTraceKey          string
+	gcpHTTPRequest       bool
+	timeFormat           string
+	utc                  bool
+	levelNames           map[zapcore.Level]string
+	// restricted lists the passed options which are supported only by some of the encoders, see newEncoderOptions
+	restricted []string
+}
+
 type Encoder struct {
 	zapcore.Encoder
-	pool        buffer.Pool
 	specialKeys map[string]struct{}
+	options     EncoderOptions
+	limiter     fieldLimiter
+}
+
+// fieldsBuffer holds the reusable field slices of an EncodeEntry call, see getFieldsBuffer.
+type fieldsBuffer struct {
+	special []zapcore.Field
+	rest    []zapcore.Field
+	stack   []zapcore.Field
 }
 
+const (
+	// TimeFormatEpoch formats the time as seconds since the Unix epoch with nanosecond precision (eg. 1257894000.000000001), see TimeFormat.
+	TimeFormatEpoch = "epoch"
+	// TimeFormatEpochMillis formats the time as milliseconds since the Unix epoch, see TimeFormat.
+	TimeFormatEpochMillis = "epoch_millis"
+	// TimeFormatEpochNanos formats the time as nanoseconds since the Unix epoch, see TimeFormat.
+	TimeFormatEpochNanos = "epoch_nanos"
+)
+
+// the names of the options supported only by some of the encoders
+const (
+	optStackTraceDepth = "StackTraceDepth"
+	optFormatVersion   = "FormatVersion"
+	optTimeFormat      = "TimeFormat"
+	optUTC             = "UTC"
+	optLevelNames      = "LevelNames"
+	optECSFieldMapping = "ECSFieldMapping"
+	optGCPTrace        = "GCPTrace"
+	optGCPHTTPRequest  = "GCPHTTPRequest"
+)
+
+const (
+	// FormatV1 is the original dliver format, the special keys and values are stripped of ';' and '='.
+	FormatV1 = 1
+	// FormatV2 marks the special key section with '##v2<' and escapes the special keys and values reversibly, see EscapeSpecial.
+	// The logger section is always written, as '<path escaped logger name> - ' or as '- ' without a logger name.
+	FormatV2 = 2
+)
+
 var msgReplacer = strings.NewReplacer("\n", "\\n", "\r", "\\r")
 var keyReplacer = strings.NewReplacer("\n", "\\n", "\r", "\\r", "=", "", ";", "")
 var valueReplacer = keyReplacer
+var specialEscaper = strings.NewReplacer("%", "%25", "=", "%3D", ";", "%3B", ">", "%3E", "\n", "%0A", "\r", "%0D")
+
+// bufferPool is shared by the encoders, zap returns the buffers to it after writing them.
+var bufferPool = buffer.NewPool()
+
+var fieldsBufferPool = sync.Pool{
+	New: func() interface{} {
+		return &fieldsBuffer{}
+	},
+}
 
 // NewEncoder create a new zapcore.Encoder configured for the dliver system needs.
 // During encoding field names matching a specialKeys entry will be added to the log message separately from the other fields.
-// Special field keys and values may not include ';' and '='. These characters will be replaced with empty string.
-func NewEncoder(specialKeys []string) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+// Special field keys and values may not include ';' and '='. These characters will be replaced with empty string,
+// unless FormatV2 is used (see FormatVersion).
+// Special field values of any type are formatted as strings, the same way as they would be in the JSON fields.
+// The time is written in the time.RFC3339Nano format in the local time zone and the DPanic, Panic and Fatal levels
+// are written as error by default, see TimeFormat, UTC and LevelNames.
+// Stack traces of errors, of the log call site (see CaptureStack) or of zap (see zap.AddStacktrace) will be added
+// to the StacktraceKey field as a list of frames for error and above levels by default. The errorVerbose field
+// is not added for the error the stack trace is taken from.
+// The message will be truncated to 500 bytes by default, the fields are not limited by default, see FieldSizeLimit and EntrySizeLimit.
+func NewEncoder(specialKeys []string, options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
 	return func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
-		// copy the struct
-		jsonCfg := cfg
-		jsonCfg.MessageKey = ""
-		jsonCfg.LevelKey = ""
-		jsonCfg.TimeKey = ""
-		jsonCfg.NameKey = ""
-		jsonCfg.CallerKey = ""
-		jsonCfg.StacktraceKey = ""
-		jsonCfg.LineEnding = ""
-
-		k := make(map[string]struct{}, len(specialKeys))
-		for _, v := range specialKeys {
-			k[v] = struct{}{}
+		eo, err := newEncoderOptions(EncoderType, options, optStackTraceDepth, optFormatVersion, optTimeFormat, optUTC, optLevelNames)
+		if err != nil {
+			return nil, err
+		}
+		if err := eo.checkLineSections(EncoderType); err != nil {
+			return nil, err
 		}
 
+		jsonCfg := fieldsEncoderConfig(cfg)
+
 		return &Encoder{
 			Encoder:     zapcore.NewJSONEncoder(jsonCfg),
-			pool:        buffer.NewPool(),
-			specialKeys: k,
+			specialKeys: keySet(specialKeys),
+			options:     eo,
+			limiter:     newFieldLimiter(jsonCfg, eo.fieldSizeLimit, eo.entrySizeLimit),
 		}, nil
 	}
 }
 
+// newEncoderOptions applies the options, returns an error if an option not listed in supported is passed,
+// which is supported only by other encoders.
+func newEncoderOptions(encoderType string, options []EncoderOption, supported ...string) (EncoderOptions, error) {
+	eo := EncoderOptions{
+		stackTraceDepth: maxStackDepth,
+		stackTraceLevels: map[zapcore.Level]struct{}{
+			zapcore.ErrorLevel:  {},
+			zapcore.DPanicLevel: {},
+			zapcore.PanicLevel:  {},
+			zapcore.FatalLevel:  {},
+		},
+		messageTruncateLimit: messageTruncateLimit,
+		formatVersion:        FormatV1,
+		gcpTraceKey:          defaultGCPTraceKey,
+		timeFormat:           time.RFC3339Nano,
+	}
+
+	for _, option := range options {
+		option(&eo)
+	}
+
+	for _, name := range eo.restricted {
+		if !containsString(supported, name) {
+			return eo, errors.Errorf("zaplog: the %v option is not supported by the %v encoder", name, encoderType)
+		}
+	}
+
+	return eo, nil
+}
+
+// checkLineSections checks that the time and the level names are written without spaces by the dliver and logfmt encoders,
+// the sections of their lines are separated by spaces (see dliverlog.Parse).
+func (eo *EncoderOptions) checkLineSections(encoderType string) error {
+	switch eo.timeFormat {
+	case TimeFormatEpoch, TimeFormatEpochMillis, TimeFormatEpochNanos:
+	default:
+		// the padded layout elements (eg. _2) may add spaces only for some of the dates
+		formatted := time.Date(2006, 1, 2, 3, 4, 5, 0, time.UTC).Format(eo.timeFormat)
+		if formatted == "" || strings.IndexFunc(formatted, unicode.IsSpace) >= 0 {
+			return errors.Errorf("zaplog: the time format %q of the %v encoder may not be empty or contain spaces", eo.timeFormat, encoderType)
+		}
+	}
+
+	for l, name := range eo.levelNames {
+		if name == "" || strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+			return errors.Errorf("zaplog: the %v level name %q of the %v encoder may not be empty or contain spaces", l, name, encoderType)
+		}
+	}
+
+	return nil
+}
+
+func (eo *EncoderOptions) restrict(name string) {
+	eo.restricted = append(eo.restricted, name)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fieldsEncoderConfig returns the config of the JSON encoder used for encoding only the fields.
+func fieldsEncoderConfig(cfg zapcore.EncoderConfig) zapcore.EncoderConfig {
+	// copy the struct
+	jsonCfg := cfg
+	jsonCfg.MessageKey = ""
+	jsonCfg.LevelKey = ""
+	jsonCfg.TimeKey = ""
+	jsonCfg.NameKey = ""
+	jsonCfg.CallerKey = ""
+	jsonCfg.StacktraceKey = ""
+	jsonCfg.LineEnding = ""
+
+	return jsonCfg
+}
+
+func keySet(keys []string) map[string]struct{} {
+	k := make(map[string]struct{}, len(keys))
+	for _, v := range keys {
+		k[v] = struct{}{}
+	}
+
+	return k
+}
+
+// StackTraceDepth limits the number of frames in the stack traces, depth <= 0 means no limit.
+// Supported by the dliver, logfmt and MessagePack encoders.
+func StackTraceDepth(depth int) EncoderOption {
+	return func(eo *EncoderOptions) {
+		eo.restrict(optStackTraceDepth)
+		eo.stackTraceDepth = depth
+	}
+}
+
+// StackTraceLevels sets the levels on which stack traces will be added to the log entries.
+// Calling it without levels disables the stack traces.
+func StackTraceLevels(levels ...zapcore.Level) EncoderOption {
+	return func(eo *EncoderOptions) {
+		eo.stackTraceLevels = make(map[zapcore.Level]struct{}, len(levels))
+		for _, l := range levels {
+			eo.stackTraceLevels[l] = struct{}{}
+		}
+	}
+}
+
+// MessageTruncateLimit sets the maximum length of the message in bytes, limit <= 0 means no limit.
+func MessageTruncateLimit(limit int) EncoderOption {
+	return func(eo *EncoderOptions) {
+		eo.messageTruncateLimit = limit
+	}
+}
+
+// FieldSizeLimit sets the maximum size of the encoded field values in bytes, limit <= 0 means no limit.
+// Larger values (strings, byte slices, nested objects, etc.) will be replaced with a truncated string
+// and their keys will be listed in the TruncatedKey field.
+func FieldSizeLimit(limit int) EncoderOption {
+	return func(eo *EncoderOptions) {
+		eo.fieldSizeLimit = limit
+	}
+}
+
+// EntrySizeLimit sets the maximum size of the log entries in bytes, limit <= 0 means no limit.
+// The fields not fitting into the limit will be truncated or dropped, and their keys will be listed in the TruncatedKey field.
+// The fields added to the logger (eg. by zap.Logger.With) are not limited.
+func EntrySizeLimit(limit int) EncoderOption {
+	return func(eo *EncoderOptions) {
+		eo.entrySizeLimit = limit
+	}
+}
+
+// FormatVersion sets the version of the dliver format, FormatV1 by default. Supported only by the dliver encoder.
+func FormatVersion(version int) EncoderOption {
+	return func(eo *EncoderOptions) {
+		eo.restrict(optFormatVersion)
+		eo.formatVersion = version
+	}
+}
+
+// TimeFormat sets the format of the entry time in the dliver and logfmt encoders, time.RFC3339Nano by default.
+// The format is a time.Time.Format layout or one of the TimeFormatEpoch, TimeFormatEpochMillis and TimeFormatEpochNanos constants.
+// The dliver and logfmt encoders return an error for layouts formatting the time with spaces, to keep the log lines parsable (see dliverlog.Parse).
+func TimeFormat(format string) EncoderOption {
+	return func(eo *EncoderOptions) {
+		eo.restrict(optTimeFormat)
+		eo.timeFormat = format
+	}
+}
+
+// UTC makes the dliver and logfmt encoders write the entry time in UTC instead of the local time zone.
+func UTC(enabled bool) EncoderOption {
+	return func(eo *EncoderOptions) {
+		eo.restrict(optUTC)
+		eo.utc = enabled
+	}
+}
+
+// LevelNames overrides the level names written by the dliver, logfmt and MessagePack encoders.
+// By default the DPanic, Panic and Fatal levels are written as error, eg. LevelNames(map[zapcore.Level]string{zapcore.PanicLevel: "panic"})
+// writes the Panic level as panic. The dliver and logfmt encoders return an error for empty names and names containing spaces.
+func LevelNames(names map[zapcore.Level]string) EncoderOption {
+	return func(eo *EncoderOptions) {
+		eo.restrict(optLevelNames)
+		if eo.levelNames == nil {
+			eo.levelNames = make(map[zapcore.Level]string, len(names))
+		}
+		for l, name := range names {
+			eo.levelNames[l] = name
+		}
+	}
+}
+
+// EscapeSpecial escapes the '%', '=', ';', '>', '\n' and '\r' characters of the special keys and values in the FormatV2 dliver format.
+func EscapeSpecial(s string) string {
+	return specialEscaper.Replace(s)
+}
+
+// UnescapeSpecial reverts EscapeSpecial.
+func UnescapeSpecial(s string) (string, error) {
+	return url.PathUnescape(s)
+}
+
 func (de *Encoder) Clone() zapcore.Encoder {
 	return &Encoder{
 		Encoder:     de.Encoder.Clone(),
-		pool:        buffer.NewPool(),
 		specialKeys: de.specialKeys,
+		options:     de.options,
+		limiter:     de.limiter,
 	}
 }
 
 func (de *Encoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
-	buf := de.pool.Get()
+	fb := getFieldsBuffer()
+	defer fb.free()
 
-	buf.AppendString(entry.Time.Format(time.RFC3339Nano))
-	buf.AppendString(" ")
-	buf.AppendString(levelToString(entry.Level))
-	buf.AppendString(" ")
-	if entry.LoggerName != "" {
+	buf := bufferPool.Get()
+
+	de.options.appendTime(buf, entry.Time)
+	buf.AppendByte(' ')
+	buf.AppendString(de.options.levelName(entry.Level))
+	buf.AppendByte(' ')
+	if de.options.formatVersion == FormatV2 {
+		// the logger section is always written in v2, so the messages containing ' - ' can't be mistaken for logger names
+		if entry.LoggerName != "" {
+			buf.AppendString(url.PathEscape(entry.LoggerName))
+			buf.AppendByte(' ')
+		}
+		buf.AppendString("- ")
+	} else if entry.LoggerName != "" {
 		buf.AppendString(entry.LoggerName)
 		buf.AppendString(" - ")
 	}
 
-	buf.AppendString(log.Truncate(msgReplacer.Replace(entry.Message), messageTruncateLimit, "..."))
-	buf.AppendString(" ##<")
+	message := msgReplacer.Replace(entry.Message)
+	if de.options.messageTruncateLimit > 0 {
+		message = log.Truncate(message, de.options.messageTruncateLimit, truncateConcat)
+	}
+	buf.AppendString(message)
+	if de.options.formatVersion == FormatV2 {
+		buf.AppendString(" ##v2<")
+	} else {
+		buf.AppendString(" ##<")
+	}
 
-	for i := len(fields) - 1; i >= 0; i-- {
-		field := fields[i]
-		if _, ok := de.specialKeys[field.Key]; ok {
-			buf.AppendString(keyReplacer.Replace(field.Key) + "=")
-			buf.AppendString(valueReplacer.Replace(field.String))
-			buf.AppendString(";")
-			fields = append(fields[0:i], fields[i+1:]...)
+	special, fields := fb.specialFields(fields, de.specialKeys)
+	for _, field := range special {
+		if de.options.formatVersion == FormatV2 {
+			buf.AppendString(EscapeSpecial(field.Key))
+			buf.AppendByte('=')
+			buf.AppendString(EscapeSpecial(fieldString(field)))
+		} else {
+			buf.AppendString(keyReplacer.Replace(field.Key))
+			buf.AppendByte('=')
+			buf.AppendString(valueReplacer.Replace(fieldString(field)))
 		}
+		buf.AppendByte(';')
 	}
 	buf.AppendString(">##")
 
+	fields = de.options.addStackTrace(fb, entry, fields)
+	fields = de.limiter.limit(fields, buf.Len())
+
 	fieldsBuf, err := de.Encoder.EncodeEntry(entry, fields)
 	if err != nil {
+		buf.Free()
 		return nil, err
 	}
 
 	_, _ = buf.Write(fieldsBuf.Bytes())
+	fieldsBuf.Free()
 
 	return buf, nil
 }
 
+func (eo EncoderOptions) appendTime(buf *buffer.Buffer, t time.Time) {
+	if eo.utc {
+		t = t.UTC()
+	}
+
+	// the time is formatted into arrays on the stack to avoid allocations
+	switch eo.timeFormat {
+	case TimeFormatEpoch:
+		buf.AppendInt(t.Unix())
+		buf.AppendByte('.')
+		var nanos [9]byte
+		n := t.Nanosecond()
+		for i := len(nanos) - 1; i >= 0; i-- {
+			nanos[i] = byte('0' + n%10)
+			n /= 10
+		}
+		_, _ = buf.Write(nanos[:])
+	case TimeFormatEpochMillis:
+		buf.AppendInt(t.UnixNano() / int64(time.Millisecond))
+	case TimeFormatEpochNanos:
+		buf.AppendInt(t.UnixNano())
+	default:
+		var formatted [64]byte
+		_, _ = buf.Write(t.AppendFormat(formatted[:0], eo.timeFormat))
+	}
+}
+
+func (eo EncoderOptions) levelName(l zapcore.Level) string {
+	if name, ok := eo.levelNames[l]; ok {
+		return name
+	}
+
+	return levelToString(l)
+}
+
+// addStackTrace replaces the stack trace in the fields with a structured one, if it is enabled for the entry level.
+// The returned slice may be backed by fb, it is valid until fb is freed.
+// The stack trace of the first error is used, then the log call site stack trace and finally the one captured by zap.
+// The error the stack trace is taken from is added only by its message, without the <key>Verbose field.
+func (eo EncoderOptions) addStackTrace(fb *fieldsBuffer, entry zapcore.Entry, fields []zapcore.Field) []zapcore.Field {
+	if _, ok := eo.stackTraceLevels[entry.Level]; !ok {
+		return fields
+	}
+
+	var errFrames, callFrames stackFrames
+	newFields := fb.stack[:0]
+	for _, f := range fields {
+		if st, ok := f.Interface.(stackTrace); ok && f.Key == StacktraceKey {
+			callFrames = framesOf(errors.StackTrace(st), eo.stackTraceDepth)
+			continue
+		}
+
+		if err, ok := f.Interface.(error); ok && f.Type == zapcore.ErrorType && errFrames == nil {
+			if st := errorStackTrace(err); st != nil {
+				errFrames = framesOf(st, eo.stackTraceDepth)
+				// zap would add the stack trace again to the <key>Verbose field
+				f = zap.String(f.Key, err.Error())
+			}
+		}
+		newFields = append(newFields, f)
+	}
+
+	frames := errFrames
+	if frames == nil {
+		frames = callFrames
+	}
+	if frames == nil && entry.Stack != "" {
+		frames = parseStack(entry.Stack, eo.stackTraceDepth)
+	}
+	if frames == nil {
+		fb.stack = newFields
+		return fields
+	}
+
+	fb.stack = append(newFields, zap.Array(StacktraceKey, frames))
+
+	return fb.stack
+}
+
+func getFieldsBuffer() *fieldsBuffer {
+	return fieldsBufferPool.Get().(*fieldsBuffer)
+}
+
+// free returns fb to the pool, the fields are cleared to not keep the logged values alive.
+func (fb *fieldsBuffer) free() {
+	for i := range fb.special {
+		fb.special[i] = zapcore.Field{}
+	}
+	for i := range fb.rest {
+		fb.rest[i] = zapcore.Field{}
+	}
+	for i := range fb.stack {
+		fb.stack[i] = zapcore.Field{}
+	}
+	fb.special = fb.special[:0]
+	fb.rest = fb.rest[:0]
+	fb.stack = fb.stack[:0]
+
+	fieldsBufferPool.Put(fb)
+}
+
+// specialFields separates the fields with special keys from the other fields, without modifying the passed slice.
+// The returned slices are backed by fb, they are valid until fb is freed. If there are no special fields,
+// the passed slice is returned as the rest.
+// The special fields are returned in reverse order, the last value wins if a special key is added multiple times.
+func (fb *fieldsBuffer) specialFields(fields []zapcore.Field, specialKeys map[string]struct{}) (special []zapcore.Field, rest []zapcore.Field) {
+	if len(specialKeys) == 0 {
+		return nil, fields
+	}
+
+	for i := len(fields) - 1; i >= 0; i-- {
+		field := fields[i]
+		if _, ok := specialKeys[field.Key]; !ok {
+			continue
+		}
+
+		if !containsField(fb.special, field.Key) {
+			fb.special = append(fb.special, field)
+		}
+	}
+	if len(fb.special) == 0 {
+		return nil, fields
+	}
+
+	for _, field := range fields {
+		if _, ok := specialKeys[field.Key]; !ok {
+			fb.rest = append(fb.rest, field)
+		}
+	}
+
+	return fb.special, fb.rest
+}
+
+func containsField(fields []zapcore.Field, key string) bool {
+	for _, f := range fields {
+		if f.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
 func levelToString(lvl zapcore.Level) string {
 	switch lvl {
 	case zapcore.DebugLevel:
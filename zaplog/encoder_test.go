@@ -0,0 +1,224 @@
+package zaplog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	stderrors "errors"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newTestEncoder(t testing.TB, specialKeys []string, options ...EncoderOption) zapcore.Encoder {
+	t.Helper()
+
+	enc, err := NewEncoder(specialKeys, options...)(zap.NewProductionEncoderConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return enc
+}
+
+func testEntry(level zapcore.Level, msg string) zapcore.Entry {
+	return zapcore.Entry{
+		Level:   level,
+		Time:    time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+		Message: msg,
+	}
+}
+
+func TestEncoderStackTraceWithoutVerbose(t *testing.T) {
+	enc := newTestEncoder(t, nil)
+
+	buf, err := enc.EncodeEntry(testEntry(zapcore.ErrorLevel, "failed"), []zapcore.Field{
+		zap.Error(errors.New("boom")),
+		zap.NamedError("other", errors.New("bang")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := buf.String()
+
+	if !strings.Contains(line, `"error":"boom"`) {
+		t.Errorf("missing error message: %v", line)
+	}
+	if strings.Contains(line, `"errorVerbose"`) {
+		t.Errorf("errorVerbose added beside the structured stack trace: %v", line)
+	}
+	if !strings.Contains(line, `"`+StacktraceKey+`":[{"function":`) {
+		t.Errorf("missing structured stack trace: %v", line)
+	}
+	// the stack trace of the other error is kept in its verbose field
+	if !strings.Contains(line, `"otherVerbose"`) {
+		t.Errorf("missing otherVerbose: %v", line)
+	}
+}
+
+func TestEncoderVerboseWithoutStackTraceLevel(t *testing.T) {
+	enc := newTestEncoder(t, nil)
+
+	buf, err := enc.EncodeEntry(testEntry(zapcore.InfoLevel, "failed"), []zapcore.Field{zap.Error(errors.New("boom"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := buf.String()
+
+	if !strings.Contains(line, `"errorVerbose"`) || strings.Contains(line, `"`+StacktraceKey+`"`) {
+		t.Errorf("unexpected stack trace fields: %v", line)
+	}
+}
+
+func TestUnsupportedEncoderOptions(t *testing.T) {
+	type constructor func(options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error)
+	dliver := func(options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return NewEncoder(nil, options...)
+	}
+	logfmt := func(options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return NewLogfmtEncoder(nil, options...)
+	}
+	gelf := func(options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return NewGELFEncoder("host", options...)
+	}
+
+	tests := []struct {
+		name      string
+		new       constructor
+		option    EncoderOption
+		supported bool
+	}{
+		{name: "dliver format version", new: dliver, option: FormatVersion(FormatV2), supported: true},
+		{name: "dliver ECS field mapping", new: dliver, option: ECSFieldMapping(nil)},
+		{name: "logfmt time format", new: logfmt, option: TimeFormat(TimeFormatEpoch), supported: true},
+		{name: "logfmt format version", new: logfmt, option: FormatVersion(FormatV2)},
+		{name: "ECS field mapping", new: NewECSEncoder, option: ECSFieldMapping(nil), supported: true},
+		{name: "ECS format version", new: NewECSEncoder, option: FormatVersion(FormatV2)},
+		{name: "ECS stack trace depth", new: NewECSEncoder, option: StackTraceDepth(5)},
+		{name: "ECS size limit", new: NewECSEncoder, option: EntrySizeLimit(100), supported: true},
+		{name: "GELF level names", new: gelf, option: LevelNames(nil)},
+		{name: "GCP trace", new: NewGCPEncoder, option: GCPTrace("trace_id", "project"), supported: true},
+		{name: "GCP HTTP request", new: NewGCPEncoder, option: GCPHTTPRequest(true), supported: true},
+		{name: "GCP time format", new: NewGCPEncoder, option: TimeFormat(TimeFormatEpoch)},
+		{name: "dliver GCP trace", new: dliver, option: GCPTrace("trace_id", "project")},
+		{name: "ECS GCP HTTP request", new: NewECSEncoder, option: GCPHTTPRequest(true)},
+	}
+
+	for _, test := range tests {
+		_, err := test.new(test.option)(zap.NewProductionEncoderConfig())
+		if test.supported && err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+		}
+		if !test.supported && err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+}
+
+var allocFields = []zapcore.Field{zap.String("correlation_id", "abc"), zap.Int("count", 1), zap.String("name", "value")}
+
+func TestEncoderAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the pooled buffers are dropped with the race detector")
+	}
+
+	jsonEnc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	enc := newTestEncoder(t, []string{"correlation_id"})
+
+	allocs := func(enc zapcore.Encoder, level zapcore.Level, fields []zapcore.Field) float64 {
+		return testing.AllocsPerRun(100, func() {
+			buf, err := enc.EncodeEntry(testEntry(level, "message"), fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf.Free()
+		})
+	}
+
+	tests := []struct {
+		name   string
+		level  zapcore.Level
+		fields []zapcore.Field
+	}{
+		{name: "info", level: zapcore.InfoLevel, fields: allocFields},
+		{name: "error", level: zapcore.ErrorLevel, fields: allocFields},
+		{name: "error without stack trace", level: zapcore.ErrorLevel, fields: append([]zapcore.Field{zap.Error(stderrors.New("boom"))}, allocFields...)},
+	}
+	for _, test := range tests {
+		if n := allocs(enc, test.level, test.fields); n != 0 {
+			t.Errorf("%v: expected no allocations, got %v", test.name, n)
+		}
+	}
+
+	// the structured stack trace allocates, but not more than the verbose error of the zap JSON encoder
+	withStack := append([]zapcore.Field{zap.Error(errors.New("boom"))}, allocFields...)
+	jsonAllocs := allocs(jsonEnc, zapcore.ErrorLevel, withStack)
+	if n := allocs(enc, zapcore.ErrorLevel, withStack); n > jsonAllocs {
+		t.Errorf("error with stack trace: expected at most %v allocations, got %v", jsonAllocs, n)
+	}
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	encoders := []struct {
+		name string
+		enc  zapcore.Encoder
+	}{
+		{name: "zap json", enc: zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())},
+		{name: "dliver", enc: newTestEncoder(b, []string{"correlation_id"})},
+	}
+	benchmarks := []struct {
+		name   string
+		level  zapcore.Level
+		fields []zapcore.Field
+	}{
+		{name: "info", level: zapcore.InfoLevel, fields: allocFields},
+		{name: "error", level: zapcore.ErrorLevel, fields: append([]zapcore.Field{zap.Error(errors.New("boom"))}, allocFields...)},
+	}
+
+	for _, e := range encoders {
+		for _, bm := range benchmarks {
+			enc, entry, fields := e.enc, testEntry(bm.level, "message"), bm.fields
+			b.Run(e.name+"/"+bm.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					buf, err := enc.EncodeEntry(entry, fields)
+					if err != nil {
+						b.Fatal(err)
+					}
+					buf.Free()
+				}
+			})
+		}
+	}
+}
+
+func TestEncoderLineSectionOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		option EncoderOption
+		valid  bool
+	}{
+		{name: "RFC3339", option: TimeFormat(time.RFC3339), valid: true},
+		{name: "epoch", option: TimeFormat(TimeFormatEpochMillis), valid: true},
+		{name: "RFC1123", option: TimeFormat(time.RFC1123)},
+		{name: "padded day", option: TimeFormat("2006-01-_2T15:04:05")},
+		{name: "empty time format", option: TimeFormat("")},
+		{name: "level name", option: LevelNames(map[zapcore.Level]string{zapcore.PanicLevel: "panic"}), valid: true},
+		{name: "level name with space", option: LevelNames(map[zapcore.Level]string{zapcore.PanicLevel: "very bad"})},
+		{name: "empty level name", option: LevelNames(map[zapcore.Level]string{zapcore.WarnLevel: ""})},
+	}
+
+	for _, test := range tests {
+		for _, newEncoder := range []func(specialKeys []string, options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error){NewEncoder, NewLogfmtEncoder} {
+			_, err := newEncoder(nil, test.option)(zap.NewProductionEncoderConfig())
+			if test.valid && err != nil {
+				t.Errorf("%v: unexpected error: %v", test.name, err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("%v: expected an error", test.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package zaplog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// fieldString formats the value of f as a string.
+func fieldString(f zapcore.Field) (str string) {
+	defer func() {
+		// the Stringer and marshaler implementations may panic
+		if r := recover(); r != nil {
+			str = fmt.Sprintf("!PANIC(%v)", r)
+		}
+	}()
+
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String
+	case zapcore.BoolType:
+		return strconv.FormatBool(f.Integer == 1)
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return strconv.FormatInt(f.Integer, 10)
+	case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type, zapcore.UintptrType:
+		return strconv.FormatUint(uint64(f.Integer), 10)
+	case zapcore.Float64Type:
+		return strconv.FormatFloat(math.Float64frombits(uint64(f.Integer)), 'f', -1, 64)
+	case zapcore.Float32Type:
+		return strconv.FormatFloat(float64(math.Float32frombits(uint32(f.Integer))), 'f', -1, 32)
+	case zapcore.Complex128Type, zapcore.Complex64Type:
+		return fmt.Sprint(f.Interface)
+	case zapcore.DurationType:
+		return time.Duration(f.Integer).String()
+	case zapcore.TimeType:
+		t := time.Unix(0, f.Integer)
+		if loc, ok := f.Interface.(*time.Location); ok {
+			t = t.In(loc)
+		}
+		return t.Format(time.RFC3339Nano)
+	case zapcore.ByteStringType:
+		return string(f.Interface.([]byte))
+	case zapcore.BinaryType:
+		return base64.StdEncoding.EncodeToString(f.Interface.([]byte))
+	case zapcore.StringerType:
+		return f.Interface.(fmt.Stringer).String()
+	case zapcore.ErrorType:
+		return f.Interface.(error).Error()
+	case zapcore.ReflectType:
+		if s, ok := f.Interface.(string); ok {
+			return s
+		}
+		return jsonString(f.Interface)
+	case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType:
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		return jsonString(enc.Fields[f.Key])
+	default:
+		return ""
+	}
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
@@ -0,0 +1,119 @@
+package zaplog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// flatField is a field with a primitive value: nil, string, bool, int64, uint64, float64 or json.Number.
+type flatField struct {
+	key   string
+	value interface{}
+}
+
+// flattenFields flattens the fields, the nested objects and arrays are flattened with dotted keys (eg. "request.header.0").
+func flattenFields(flat []flatField, prefix string, fields []zapcore.Field) []flatField {
+	for _, f := range fields {
+		switch f.Type {
+		case zapcore.NamespaceType:
+			prefix = joinKey(prefix, f.Key)
+			continue
+		case zapcore.SkipType:
+			continue
+		}
+
+		enc := zapcore.NewMapObjectEncoder()
+		addField(enc, f)
+		flat = flattenMap(flat, prefix, enc.Fields)
+	}
+
+	return flat
+}
+
+// addField adds f to enc, recovering the panics of the Stringer and marshaler implementations.
+func addField(enc zapcore.ObjectEncoder, f zapcore.Field) {
+	defer func() {
+		if r := recover(); r != nil {
+			enc.AddString(f.Key, fmt.Sprintf("!PANIC(%v)", r))
+		}
+	}()
+
+	f.AddTo(enc)
+}
+
+func flattenMap(flat []flatField, prefix string, m map[string]interface{}) []flatField {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		flat = flattenValue(flat, joinKey(prefix, k), m[k])
+	}
+
+	return flat
+}
+
+func flattenValue(flat []flatField, key string, v interface{}) []flatField {
+	switch t := v.(type) {
+	case nil, string, bool, json.Number:
+		return append(flat, flatField{key: key, value: t})
+	case []byte:
+		return append(flat, flatField{key: key, value: base64.StdEncoding.EncodeToString(t)})
+	case time.Time:
+		return append(flat, flatField{key: key, value: t.Format(time.RFC3339Nano)})
+	case time.Duration:
+		return append(flat, flatField{key: key, value: t.String()})
+	case complex64, complex128:
+		return append(flat, flatField{key: key, value: fmt.Sprint(t)})
+	case map[string]interface{}:
+		return flattenMap(flat, key, t)
+	case []interface{}:
+		for i, e := range t {
+			flat = flattenValue(flat, joinKey(key, strconv.Itoa(i)), e)
+		}
+		return flat
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return append(flat, flatField{key: key, value: rv.Int()})
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return append(flat, flatField{key: key, value: rv.Uint()})
+	case reflect.Float32, reflect.Float64:
+		return append(flat, flatField{key: key, value: rv.Float()})
+	}
+
+	// reflected values are flattened by their JSON representation
+	b, err := json.Marshal(v)
+	if err != nil {
+		return append(flat, flatField{key: key, value: fmt.Sprintf("%+v", v)})
+	}
+
+	var decoded interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&decoded); err != nil {
+		return append(flat, flatField{key: key, value: string(b)})
+	}
+
+	return flattenValue(flat, key, decoded)
+}
+
+func joinKey(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + "." + key
+}
@@ -0,0 +1,287 @@
+package zaplog
+
+import (
+	"bufio"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/proemergotech/log/v3"
+)
+
+const (
+	GCPEncoderType = "dliver-gcp"
+	// GCPTraceKey is the key of the Cloud Logging trace field.
+	GCPTraceKey        = "logging.googleapis.com/trace"
+	defaultGCPTraceKey = "correlation_id"
+)
+
+type gcpEncoder struct {
+	zapcore.Encoder
+	options EncoderOptions
+	limiter fieldLimiter
+}
+
+type gcpSourceLocation struct {
+	file     string
+	line     int
+	function string
+}
+
+type gcpHTTPRequest struct {
+	method       string
+	url          string
+	protocol     string
+	userAgent    string
+	referer      string
+	requestSize  int64
+	status       int
+	responseSize int64
+}
+
+// NewGCPEncoder create a new zapcore.Encoder writing JSON lines in the Google Cloud Logging structured logging format.
+// The levels are mapped to the severity field (eg. Panic to CRITICAL), the caller to sourceLocation,
+// the correlation id to the GCPTraceKey field, see GCPTrace.
+// The request and response dumps of the echolog and httplog packages can be converted to httpRequest, see GCPHTTPRequest.
+// The stack traces are written to the stack_trace field, the truncation and the size limits are handled the same way as by NewEncoder.
+func NewGCPEncoder(options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+	return func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		eo, err := newEncoderOptions(GCPEncoderType, options, optGCPTrace, optGCPHTTPRequest)
+		if err != nil {
+			return nil, err
+		}
+
+		// copy the struct
+		gcpCfg := cfg
+		gcpCfg.TimeKey = "time"
+		gcpCfg.LevelKey = "severity"
+		gcpCfg.NameKey = "logger"
+		gcpCfg.MessageKey = "message"
+		gcpCfg.CallerKey = ""
+		gcpCfg.StacktraceKey = ""
+		gcpCfg.LineEnding = "\n"
+		gcpCfg.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(t.UTC().Format(time.RFC3339Nano))
+		}
+		gcpCfg.EncodeLevel = gcpLevelEncoder
+		if gcpCfg.EncodeDuration == nil {
+			gcpCfg.EncodeDuration = zapcore.StringDurationEncoder
+		}
+		gcpCfg.EncodeName = nil
+
+		return &gcpEncoder{
+			Encoder: zapcore.NewJSONEncoder(gcpCfg),
+			options: eo,
+			limiter: newFieldLimiter(fieldsEncoderConfig(cfg), eo.fieldSizeLimit, eo.entrySizeLimit),
+		}, nil
+	}
+}
+
+// GCPTrace sets the key of the field written to the GCPTraceKey field of the GCP encoder, correlation_id by default.
+// If projectID is not empty, the trace is written in the projects/<projectID>/traces/<trace> format expected by Cloud Trace.
+func GCPTrace(key string, projectID string) EncoderOption {
+	return func(eo *EncoderOptions) {
+		eo.restrict(optGCPTrace)
+		eo.gcpTraceKey = key
+		eo.gcpProjectID = projectID
+	}
+}
+
+// GCPHTTPRequest makes the GCP encoder parse the request and response dumps of the echolog and httplog packages
+// into the httpRequest field. The original fields are kept.
+func GCPHTTPRequest(enabled bool) EncoderOption {
+	return func(eo *EncoderOptions) {
+		eo.restrict(optGCPHTTPRequest)
+		eo.gcpHTTPRequest = enabled
+	}
+}
+
+func (ge *gcpEncoder) Clone() zapcore.Encoder {
+	return &gcpEncoder{
+		Encoder: ge.Encoder.Clone(),
+		options: ge.options,
+		limiter: ge.limiter,
+	}
+}
+
+// AddString writes the trace field added to the logger (eg. by zap.Logger.With) to the GCPTraceKey field.
+func (ge *gcpEncoder) AddString(key, value string) {
+	if key == ge.options.gcpTraceKey {
+		ge.Encoder.AddString(GCPTraceKey, ge.trace(value))
+		return
+	}
+
+	ge.Encoder.AddString(key, value)
+}
+
+func (ge *gcpEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	if ge.options.messageTruncateLimit > 0 {
+		entry.Message = log.Truncate(entry.Message, ge.options.messageTruncateLimit, truncateConcat)
+	}
+
+	_, stackEnabled := ge.options.stackTraceLevels[entry.Level]
+
+	gcpFields := make([]zapcore.Field, 0, len(fields)+3)
+	if entry.Caller.Defined {
+		location := gcpSourceLocation{file: entry.Caller.File, line: entry.Caller.Line}
+		if fn := runtime.FuncForPC(entry.Caller.PC); fn != nil {
+			location.function = fn.Name()
+		}
+		gcpFields = append(gcpFields, zap.Object("logging.googleapis.com/sourceLocation", location))
+	}
+
+	var errorFound bool
+	var stack string
+	var httpRequest gcpHTTPRequest
+	var httpFound bool
+	for _, f := range fields {
+		if st, ok := f.Interface.(stackTrace); ok && f.Key == StacktraceKey {
+			stack = st.String()
+			continue
+		}
+
+		if err, ok := f.Interface.(error); ok && f.Type == zapcore.ErrorType {
+			if !errorFound && hasStackTrace(err) {
+				stack = verboseError(err)
+			}
+			errorFound = true
+			// the verbose form of the error goes to stack_trace
+			f = zap.String(f.Key, err.Error())
+		}
+
+		if f.Key == ge.options.gcpTraceKey && f.Type == zapcore.StringType {
+			f.Key = GCPTraceKey
+			f.String = ge.trace(f.String)
+		}
+
+		if ge.options.gcpHTTPRequest && httpRequest.add(f) {
+			httpFound = true
+		}
+
+		gcpFields = append(gcpFields, f)
+	}
+
+	if httpFound && (httpRequest.method != "" || httpRequest.status != 0) {
+		gcpFields = append(gcpFields, zap.Object("httpRequest", httpRequest))
+	}
+
+	if stack == "" {
+		stack = entry.Stack
+	}
+	if stackEnabled && stack != "" {
+		gcpFields = append(gcpFields, zap.String("stack_trace", stack))
+	}
+	entry.Stack = ""
+
+	return ge.Encoder.EncodeEntry(entry, ge.limiter.limit(gcpFields, 0))
+}
+
+func (ge *gcpEncoder) trace(trace string) string {
+	if ge.options.gcpProjectID == "" {
+		return trace
+	}
+
+	return "projects/" + ge.options.gcpProjectID + "/traces/" + trace
+}
+
+// add parses the request and response fields of the echolog and httplog packages, returns false for other fields.
+func (hr *gcpHTTPRequest) add(f zapcore.Field) bool {
+	switch f.Key {
+	case "request":
+		if f.Type != zapcore.StringType {
+			return false
+		}
+		req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(f.String)))
+		if err != nil {
+			return false
+		}
+		hr.method = req.Method
+		hr.url = req.RequestURI
+		hr.protocol = req.Proto
+		hr.userAgent = req.UserAgent()
+		hr.referer = req.Referer()
+	case "response":
+		if f.Type != zapcore.StringType {
+			return false
+		}
+		res, err := http.ReadResponse(bufio.NewReader(strings.NewReader(f.String)), nil)
+		if err != nil {
+			return false
+		}
+		hr.status = res.StatusCode
+	case "request-length":
+		if f.Type != zapcore.Int64Type {
+			return false
+		}
+		hr.requestSize = f.Integer
+	case "response-length":
+		if f.Type != zapcore.Int64Type {
+			return false
+		}
+		hr.responseSize = f.Integer
+	default:
+		return false
+	}
+
+	return true
+}
+
+func (hr gcpHTTPRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if hr.method != "" {
+		enc.AddString("requestMethod", hr.method)
+		enc.AddString("requestUrl", hr.url)
+		enc.AddString("protocol", hr.protocol)
+	}
+	if hr.userAgent != "" {
+		enc.AddString("userAgent", hr.userAgent)
+	}
+	if hr.referer != "" {
+		enc.AddString("referer", hr.referer)
+	}
+	// the sizes are int64 values, which are represented as strings in the JSON format of the Cloud Logging API
+	if hr.requestSize > 0 {
+		enc.AddString("requestSize", strconv.FormatInt(hr.requestSize, 10))
+	}
+	if hr.status != 0 {
+		enc.AddInt("status", hr.status)
+	}
+	if hr.responseSize > 0 {
+		enc.AddString("responseSize", strconv.FormatInt(hr.responseSize, 10))
+	}
+
+	return nil
+}
+
+func (sl gcpSourceLocation) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("file", sl.file)
+	enc.AddString("line", strconv.Itoa(sl.line))
+	if sl.function != "" {
+		enc.AddString("function", sl.function)
+	}
+
+	return nil
+}
+
+// gcpLevelEncoder encodes the levels as Cloud Logging severities.
+func gcpLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch l {
+	case zapcore.DebugLevel:
+		enc.AppendString("DEBUG")
+	case zapcore.InfoLevel:
+		enc.AppendString("INFO")
+	case zapcore.WarnLevel:
+		enc.AppendString("WARNING")
+	case zapcore.ErrorLevel:
+		enc.AppendString("ERROR")
+	case zapcore.DPanicLevel, zapcore.PanicLevel:
+		enc.AppendString("CRITICAL")
+	default:
+		enc.AppendString("ALERT")
+	}
+}
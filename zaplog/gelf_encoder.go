@@ -0,0 +1,221 @@
+package zaplog
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/proemergotech/log/v3"
+)
+
+const (
+	GELFEncoderType = "dliver-gelf"
+	gelfVersion     = "1.1"
+)
+
+type gelfEncoder struct {
+	*zapcore.MapObjectEncoder
+	json    zapcore.Encoder
+	host    string
+	options EncoderOptions
+	limiter fieldLimiter
+}
+
+// NewGELFEncoder create a new zapcore.Encoder writing Graylog Extended Log Format (GELF 1.1) JSON messages, see NewGELFWriter.
+// The first line of the message is written to short_message, the whole message and the stack trace of the entry to full_message.
+// The levels are mapped to syslog severities, the fields are flattened with dotted keys (eg. _request.header.0)
+// and written as additional fields prefixed with '_'. The host defaults to the hostname of the machine.
+// The truncation of the short message and the size limits are handled the same way as by NewEncoder.
+func NewGELFEncoder(host string, options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+	return func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		eo, err := newEncoderOptions(GELFEncoderType, options)
+		if err != nil {
+			return nil, err
+		}
+
+		gelfHost := host
+		if gelfHost == "" {
+			h, err := os.Hostname()
+			if err != nil {
+				return nil, err
+			}
+			gelfHost = h
+		}
+
+		// copy the struct
+		gelfCfg := cfg
+		gelfCfg.TimeKey = "timestamp"
+		gelfCfg.LevelKey = "level"
+		gelfCfg.NameKey = "_logger"
+		gelfCfg.MessageKey = "short_message"
+		gelfCfg.CallerKey = ""
+		gelfCfg.StacktraceKey = ""
+		gelfCfg.LineEnding = "\n"
+		gelfCfg.EncodeTime = zapcore.EpochTimeEncoder
+		gelfCfg.EncodeLevel = gelfLevelEncoder
+		gelfCfg.EncodeName = nil
+
+		return &gelfEncoder{
+			MapObjectEncoder: zapcore.NewMapObjectEncoder(),
+			json:             zapcore.NewJSONEncoder(gelfCfg),
+			host:             gelfHost,
+			options:          eo,
+			limiter:          newFieldLimiter(fieldsEncoderConfig(cfg), eo.fieldSizeLimit, eo.entrySizeLimit),
+		}, nil
+	}
+}
+
+func (ge *gelfEncoder) Clone() zapcore.Encoder {
+	// copy the previously added fields, see devEncoder.Clone
+	enc := zapcore.NewMapObjectEncoder()
+	for k, v := range ge.MapObjectEncoder.Fields {
+		enc.Fields[k] = v
+	}
+
+	return &gelfEncoder{
+		MapObjectEncoder: enc,
+		json:             ge.json,
+		host:             ge.host,
+		options:          ge.options,
+		limiter:          ge.limiter,
+	}
+}
+
+func (ge *gelfEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	_, stackEnabled := ge.options.stackTraceLevels[entry.Level]
+
+	fullMessage := entry.Message
+	entry.Message = strings.TrimSpace(entry.Message)
+	if i := strings.IndexAny(entry.Message, "\r\n"); i >= 0 {
+		entry.Message = entry.Message[:i]
+	}
+	if ge.options.messageTruncateLimit > 0 {
+		entry.Message = log.Truncate(entry.Message, ge.options.messageTruncateLimit, truncateConcat)
+	}
+	if entry.Message == "" {
+		// short_message is mandatory and may not be empty
+		entry.Message = "-"
+	}
+
+	var errorFound bool
+	var stack string
+	rest := make([]zapcore.Field, 0, len(fields))
+	for _, f := range ge.limiter.limit(fields, 0) {
+		if st, ok := f.Interface.(stackTrace); ok && f.Key == StacktraceKey {
+			stack = st.String()
+			continue
+		}
+
+		if err, ok := f.Interface.(error); ok && f.Type == zapcore.ErrorType {
+			if !errorFound && hasStackTrace(err) {
+				stack = verboseError(err)
+			}
+			errorFound = true
+			// the verbose form of the error goes to full_message
+			f = zap.String(f.Key, err.Error())
+		}
+		rest = append(rest, f)
+	}
+
+	if stack == "" {
+		stack = entry.Stack
+	}
+	if stackEnabled && stack != "" {
+		fullMessage += "\n\n" + stack
+	}
+	entry.Stack = ""
+
+	flat := flattenMap(nil, "", ge.MapObjectEncoder.Fields)
+	flat = flattenFields(flat, "", rest)
+
+	gelfFields := make([]zapcore.Field, 0, len(flat)+4)
+	gelfFields = append(gelfFields,
+		zap.String("version", gelfVersion),
+		zap.String("host", ge.host),
+	)
+	if fullMessage != entry.Message {
+		gelfFields = append(gelfFields, zap.String("full_message", fullMessage))
+	}
+	if entry.Caller.Defined {
+		gelfFields = append(gelfFields,
+			zap.String("_file", entry.Caller.File),
+			zap.Int("_line", entry.Caller.Line),
+		)
+	}
+	for _, f := range flat {
+		if field, ok := gelfField(f); ok {
+			gelfFields = append(gelfFields, field)
+		}
+	}
+
+	return ge.json.EncodeEntry(entry, gelfFields)
+}
+
+// gelfField converts a flattened field to a GELF additional field, the values may only be strings or numbers.
+func gelfField(f flatField) (zapcore.Field, bool) {
+	key := gelfKey(f.key)
+
+	switch v := f.value.(type) {
+	case string:
+		return zap.String(key, v), true
+	case bool:
+		return zap.String(key, strconv.FormatBool(v)), true
+	case int64:
+		return zap.Int64(key, v), true
+	case uint64:
+		return zap.Uint64(key, v), true
+	case float64:
+		return zap.Float64(key, v), true
+	case json.Number:
+		if n, err := v.Float64(); err == nil {
+			return zap.Float64(key, n), true
+		}
+		return zap.String(key, v.String()), true
+	}
+
+	return zap.Skip(), false
+}
+
+// gelfKey prefixes the key with '_' and replaces the characters not allowed by GELF with '_'.
+// The reserved _id key is renamed to _id_.
+func gelfKey(key string) string {
+	b := make([]byte, 0, len(key)+2)
+	b = append(b, '_')
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.', c == '-':
+			b = append(b, c)
+		default:
+			b = append(b, '_')
+		}
+	}
+	if string(b) == "_id" {
+		b = append(b, '_')
+	}
+
+	return string(b)
+}
+
+// gelfLevelEncoder encodes the levels as syslog severities.
+func gelfLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch l {
+	case zapcore.DebugLevel:
+		enc.AppendInt(7)
+	case zapcore.InfoLevel:
+		enc.AppendInt(6)
+	case zapcore.WarnLevel:
+		enc.AppendInt(4)
+	case zapcore.ErrorLevel:
+		enc.AppendInt(3)
+	case zapcore.DPanicLevel, zapcore.PanicLevel:
+		enc.AppendInt(2)
+	default:
+		enc.AppendInt(1)
+	}
+}
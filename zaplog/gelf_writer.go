@@ -0,0 +1,272 @@
+package zaplog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	gelfChunkSize      = 1420
+	gelfMaxChunks      = 128
+	gelfChunkHeaderLen = 12
+	gelfDialTimeout    = 5 * time.Second
+	gelfWriteTimeout   = 5 * time.Second
+)
+
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+type GELFWriterOption func(wo *GELFWriterOptions)
+
+type GELFWriterOptions struct {
+	chunkSize    int
+	compress     bool
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// GELFWriter sends the messages encoded by the GELF encoder to a Graylog input, see NewGELFWriter.
+// It implements zapcore.WriteSyncer, every Write call must contain exactly one message.
+type GELFWriter struct {
+	network string
+	address string
+	options GELFWriterOptions
+	mu      sync.Mutex
+	conn    net.Conn
+	closed  bool
+}
+
+// NewGELFWriter creates a new GELFWriter sending messages to address over network, which must be "udp" or "tcp".
+// UDP messages are gzip compressed and split into GELF chunks if they are larger than the chunk size (1420 bytes by default),
+// messages needing more than 128 chunks are rejected.
+// TCP messages are not compressed and are delimited by a null byte. The writes time out after 5 seconds by default
+// (see GELFWriteTimeout), so a stalled Graylog input blocks the logging goroutines only until then.
+// On write errors the connection is reestablished and the message is sent again once. If the failed write was partial,
+// the input may receive the truncated message as a corrupted one, or the message may be received twice.
+func NewGELFWriter(network string, address string, options ...GELFWriterOption) (*GELFWriter, error) {
+	wo := GELFWriterOptions{
+		chunkSize:    gelfChunkSize,
+		compress:     true,
+		dialTimeout:  gelfDialTimeout,
+		writeTimeout: gelfWriteTimeout,
+	}
+
+	for _, option := range options {
+		option(&wo)
+	}
+
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, errors.Errorf("gelf: unsupported network: %v", network)
+	}
+	if wo.chunkSize <= gelfChunkHeaderLen {
+		return nil, errors.Errorf("gelf: chunk size must be larger than %v bytes", gelfChunkHeaderLen)
+	}
+
+	w := &GELFWriter{
+		network: network,
+		address: address,
+		options: wo,
+	}
+
+	conn, err := w.dial()
+	if err != nil {
+		return nil, err
+	}
+	w.conn = conn
+
+	return w, nil
+}
+
+// GELFChunkSize sets the maximum size of the UDP datagrams in bytes, including the chunk header.
+func GELFChunkSize(size int) GELFWriterOption {
+	return func(wo *GELFWriterOptions) {
+		wo.chunkSize = size
+	}
+}
+
+// GELFCompress enables the gzip compression of the UDP messages, it is enabled by default.
+func GELFCompress(enabled bool) GELFWriterOption {
+	return func(wo *GELFWriterOptions) {
+		wo.compress = enabled
+	}
+}
+
+// GELFDialTimeout sets the timeout of connecting to the Graylog input, 5 seconds by default.
+func GELFDialTimeout(timeout time.Duration) GELFWriterOption {
+	return func(wo *GELFWriterOptions) {
+		wo.dialTimeout = timeout
+	}
+}
+
+// GELFWriteTimeout sets the timeout of sending a message, 5 seconds by default, timeout <= 0 means no timeout.
+func GELFWriteTimeout(timeout time.Duration) GELFWriterOption {
+	return func(wo *GELFWriterOptions) {
+		wo.writeTimeout = timeout
+	}
+}
+
+// Write sends p as one GELF message, the trailing line ending added by the encoder is removed.
+func (w *GELFWriter) Write(p []byte) (int, error) {
+	msg := bytes.TrimRight(p, "\r\n")
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errors.New("gelf: writer is closed")
+	}
+
+	var err error
+	if w.isUDP() {
+		err = w.writeUDP(msg)
+	} else {
+		err = w.writeTCP(msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Sync implements zapcore.WriteSyncer, the messages are sent by Write immediately.
+func (w *GELFWriter) Sync() error {
+	return nil
+}
+
+// Close closes the connection of the writer.
+func (w *GELFWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+
+	return err
+}
+
+func (w *GELFWriter) isUDP() bool {
+	return w.network == "udp" || w.network == "udp4" || w.network == "udp6"
+}
+
+func (w *GELFWriter) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout(w.network, w.address, w.options.dialTimeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "gelf: connecting to %v %v failed", w.network, w.address)
+	}
+
+	return conn, nil
+}
+
+func (w *GELFWriter) writeUDP(msg []byte) error {
+	if w.options.compress {
+		var err error
+		msg, err = gzipMessage(msg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := w.setWriteDeadline(); err != nil {
+		return err
+	}
+
+	if len(msg) <= w.options.chunkSize {
+		_, err := w.conn.Write(msg)
+		return errors.Wrap(err, "gelf: sending message failed")
+	}
+
+	dataSize := w.options.chunkSize - gelfChunkHeaderLen
+	count := (len(msg) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return errors.Errorf("gelf: message needs %v chunks, more than the maximum %v", count, gelfMaxChunks)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return errors.Wrap(err, "gelf: generating message id failed")
+	}
+
+	chunk := make([]byte, 0, w.options.chunkSize)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * dataSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+
+		chunk = append(chunk[:0], gelfChunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, msg[i*dataSize:end]...)
+		if _, err := w.conn.Write(chunk); err != nil {
+			return errors.Wrap(err, "gelf: sending chunk failed")
+		}
+	}
+
+	return nil
+}
+
+func (w *GELFWriter) writeTCP(msg []byte) error {
+	frame := make([]byte, 0, len(msg)+1)
+	frame = append(frame, msg...)
+	frame = append(frame, 0)
+
+	if w.conn != nil {
+		if err := w.setWriteDeadline(); err == nil {
+			if _, err := w.conn.Write(frame); err == nil {
+				return nil
+			}
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+
+	// reconnect once and resend the whole message, if the failed write was partial, the input receives
+	// the truncated frame on the closed connection, which may be parsed as a corrupted message
+	conn, err := w.dial()
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+
+	if err := w.setWriteDeadline(); err != nil {
+		return err
+	}
+	_, err = w.conn.Write(frame)
+
+	return errors.Wrap(err, "gelf: sending message failed")
+}
+
+// setWriteDeadline limits the duration of the next writes, the writes hold the lock of the writer.
+func (w *GELFWriter) setWriteDeadline() error {
+	if w.options.writeTimeout <= 0 {
+		return nil
+	}
+
+	return errors.Wrap(w.conn.SetWriteDeadline(time.Now().Add(w.options.writeTimeout)), "gelf: setting write deadline failed")
+}
+
+func gzipMessage(msg []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(msg); err != nil {
+		return nil, errors.Wrap(err, "gelf: compressing message failed")
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.Wrap(err, "gelf: compressing message failed")
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,287 @@
+package zaplog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newUDPListener(t *testing.T) net.PacketConn {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	return pc
+}
+
+func newTCPListener(t *testing.T) net.Listener {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	return l
+}
+
+func newTestGELFWriter(t *testing.T, network string, address string, options ...GELFWriterOption) *GELFWriter {
+	t.Helper()
+
+	w, err := NewGELFWriter(network, address, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+
+	return w
+}
+
+func readDatagram(t *testing.T, pc net.PacketConn) []byte {
+	t.Helper()
+
+	buf := make([]byte, 65536)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf[:n]
+}
+
+func randomMessage(t *testing.T, size int) []byte {
+	t.Helper()
+
+	// random bytes are not compressible, the message sizes are kept by gzip
+	msg := make([]byte, size)
+	if _, err := rand.Read(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	return msg
+}
+
+func TestGELFWriterUDP(t *testing.T) {
+	pc := newUDPListener(t)
+	w := newTestGELFWriter(t, "udp", pc.LocalAddr().String(), GELFCompress(false))
+
+	if _, err := w.Write([]byte(`{"short_message":"hello"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(readDatagram(t, pc)); got != `{"short_message":"hello"}` {
+		t.Errorf("expected the message without the line ending, got %q", got)
+	}
+}
+
+func TestGELFWriterUDPGzip(t *testing.T) {
+	pc := newUDPListener(t)
+	w := newTestGELFWriter(t, "udp", pc.LocalAddr().String())
+
+	msg := strings.Repeat(`{"short_message":"hello"}`, 100)
+	if _, err := w.Write([]byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(readDatagram(t, pc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != msg {
+		t.Errorf("expected %q, got %q", msg, got)
+	}
+}
+
+func TestGELFWriterUDPChunks(t *testing.T) {
+	pc := newUDPListener(t)
+	w := newTestGELFWriter(t, "udp", pc.LocalAddr().String(), GELFChunkSize(100))
+
+	// 88 bytes of data per chunk, the compressed message needs at least 12 chunks
+	msg := randomMessage(t, 1000)
+	if _, err := w.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	var id []byte
+	var data [][]byte
+	for count := 1; len(data) < count; {
+		chunk := readDatagram(t, pc)
+		if len(chunk) > 100 || len(chunk) <= gelfChunkHeaderLen {
+			t.Fatalf("invalid chunk size %v", len(chunk))
+		}
+		if !bytes.Equal(chunk[:2], gelfChunkMagic) {
+			t.Fatalf("invalid chunk magic bytes %x", chunk[:2])
+		}
+		if id == nil {
+			id = chunk[2:10]
+			count = int(chunk[11])
+			data = make([][]byte, 0, count)
+		}
+		if !bytes.Equal(chunk[2:10], id) {
+			t.Errorf("expected message id %x, got %x", id, chunk[2:10])
+		}
+		if int(chunk[10]) != len(data) || int(chunk[11]) != count {
+			t.Fatalf("expected chunk %v/%v, got %v/%v", len(data), count, chunk[10], chunk[11])
+		}
+		data = append(data, chunk[gelfChunkHeaderLen:])
+	}
+	if len(data) < 12 {
+		t.Errorf("expected at least 12 chunks, got %v", len(data))
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(bytes.Join(data, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("the reassembled message differs from the sent one")
+	}
+}
+
+func TestGELFWriterUDPTooManyChunks(t *testing.T) {
+	pc := newUDPListener(t)
+	w := newTestGELFWriter(t, "udp", pc.LocalAddr().String(), GELFChunkSize(20), GELFCompress(false))
+
+	// 8 bytes of data per chunk
+	if _, err := w.Write(randomMessage(t, 8*gelfMaxChunks)); err != nil {
+		t.Errorf("unexpected error with %v chunks: %v", gelfMaxChunks, err)
+	}
+	if _, err := w.Write(randomMessage(t, 8*gelfMaxChunks+1)); err == nil {
+		t.Errorf("expected an error with %v chunks", gelfMaxChunks+1)
+	}
+}
+
+func TestGELFWriterTCP(t *testing.T) {
+	l := newTCPListener(t)
+	w := newTestGELFWriter(t, "tcp", l.Addr().String())
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for _, msg := range []string{`{"short_message":"first"}` + "\n", `{"short_message":"second"}`} {
+		if _, err := w.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := bufio.NewReader(conn)
+	for _, want := range []string{`{"short_message":"first"}`, `{"short_message":"second"}`} {
+		got, err := r.ReadString(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want+"\x00" {
+			t.Errorf("expected %q, got %q", want+"\x00", got)
+		}
+	}
+}
+
+func TestGELFWriterTCPReconnect(t *testing.T) {
+	l := newTCPListener(t)
+	w := newTestGELFWriter(t, "tcp", l.Addr().String())
+
+	first, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	// break the connection of the writer, the next write fails and reconnects
+	_ = w.conn.Close()
+
+	if _, err := w.Write([]byte(`{"short_message":"hello"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	_ = second.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	got, err := bufio.NewReader(second).ReadString(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `{"short_message":"hello"}`+"\x00" {
+		t.Errorf("unexpected message on the new connection: %q", got)
+	}
+}
+
+func TestGELFWriterTCPWriteTimeout(t *testing.T) {
+	l := newTCPListener(t)
+	w := newTestGELFWriter(t, "tcp", l.Addr().String(), GELFWriteTimeout(50*time.Millisecond))
+
+	// the accepted connections are never read, the socket buffers fill up
+	conns := make(chan net.Conn, 100)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				close(conns)
+				return
+			}
+			conns <- conn
+		}
+	}()
+	defer func() {
+		_ = l.Close()
+		for conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+
+	// the timed out write reconnects and sends the message again on the new connection
+	first := w.conn
+	msg := bytes.Repeat([]byte("a"), 1<<20)
+	for i := 0; i < 100 && w.conn == first; i++ {
+		start := time.Now()
+		_, err := w.Write(msg)
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("the write blocked for %v", d)
+		}
+		if err != nil {
+			return
+		}
+	}
+	if w.conn == first {
+		t.Errorf("expected a write timeout")
+	}
+}
+
+func TestGELFWriterClosed(t *testing.T) {
+	pc := newUDPListener(t)
+	w := newTestGELFWriter(t, "udp", pc.LocalAddr().String())
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("{}")); err == nil {
+		t.Errorf("expected an error after Close")
+	}
+}
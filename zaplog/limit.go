@@ -0,0 +1,155 @@
+package zaplog
+
+import (
+	"encoding/base64"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/proemergotech/log/v3"
+)
+
+// TruncatedKey is the field key listing the keys of the fields truncated because of the size limits.
+const TruncatedKey = "truncated_fields"
+
+const (
+	truncateConcat = "..."
+	// size of `,"truncated_fields":[]`
+	truncatedKeyOverhead = len(TruncatedKey) + 6
+)
+
+// fieldLimiter truncates the fields exceeding the field or the entry size limits.
+// The sizes are measured in bytes of the JSON encoded field values.
+type fieldLimiter struct {
+	// scratch is used only for measuring the encoded size of the fields, nothing is added to it
+	scratch    zapcore.Encoder
+	fieldLimit int
+	entryLimit int
+}
+
+func newFieldLimiter(cfg zapcore.EncoderConfig, fieldLimit int, entryLimit int) fieldLimiter {
+	return fieldLimiter{
+		scratch:    zapcore.NewJSONEncoder(cfg),
+		fieldLimit: fieldLimit,
+		entryLimit: entryLimit,
+	}
+}
+
+// limit applies the size limits to the fields, used is the size of the already written part of the entry.
+// The fields exceeding the limits are replaced with truncated string fields (or dropped if there is no room left),
+// and their keys are listed in the TruncatedKey field, as long as they fit into the entry size limit.
+func (fl fieldLimiter) limit(fields []zapcore.Field, used int) []zapcore.Field {
+	if fl.fieldLimit <= 0 && fl.entryLimit <= 0 {
+		return fields
+	}
+
+	// size of the entry without the fields: `{`, `}` and the line ending
+	budget := fl.entryLimit - used - truncatedKeyOverhead - 3
+
+	var truncated []string
+	changed := false
+	limited := make([]zapcore.Field, 0, len(fields)+1)
+	for _, f := range fields {
+		if f.Type == zapcore.NamespaceType || f.Type == zapcore.SkipType {
+			limited = append(limited, f)
+			continue
+		}
+
+		// size of `"key":` and `,`
+		overhead := len(f.Key) + 4
+		value := fl.encodeValue(f)
+		if len(value) <= fl.valueLimit(budget, overhead) {
+			limited = append(limited, f)
+			budget -= overhead + len(value)
+			continue
+		}
+
+		changed = true
+		// the key is listed in the TruncatedKey field only if it fits into the entry
+		if keySize := len(f.Key) + 3; fl.entryLimit <= 0 || keySize <= budget {
+			truncated = append(truncated, f.Key)
+			budget -= keySize
+		}
+
+		valueLimit := fl.valueLimit(budget, overhead)
+		if valueLimit < len(truncateConcat)+2 {
+			continue
+		}
+
+		tf := fl.truncate(f, value, valueLimit)
+		limited = append(limited, tf)
+		budget -= overhead + len(fl.encodeValue(tf))
+	}
+
+	if !changed {
+		return fields
+	}
+	if len(truncated) == 0 {
+		return limited
+	}
+
+	return append(limited, zap.Strings(TruncatedKey, truncated))
+}
+
+// valueLimit returns the maximum size of a field value, based on the field size limit and the remaining entry size.
+func (fl fieldLimiter) valueLimit(budget int, overhead int) int {
+	limit := fl.fieldLimit
+	if fl.entryLimit > 0 && (limit <= 0 || budget-overhead < limit) {
+		limit = budget - overhead
+	}
+
+	return limit
+}
+
+// truncate returns a string field with the value of f truncated to fit into limit bytes when encoded.
+func (fl fieldLimiter) truncate(f zapcore.Field, value string, limit int) zapcore.Field {
+	var content string
+	switch f.Type {
+	case zapcore.StringType:
+		content = f.String
+	case zapcore.ByteStringType:
+		content = string(f.Interface.([]byte))
+	case zapcore.BinaryType:
+		content = base64.StdEncoding.EncodeToString(f.Interface.([]byte))
+	case zapcore.ErrorType:
+		content = f.Interface.(error).Error()
+	default:
+		content = value
+	}
+
+	// the escaping may make the encoded value longer than the content, retry with the excess removed
+	length := limit - 2
+	tf := zap.String(f.Key, log.Truncate(content, length, truncateConcat))
+	for i := 0; i < 3; i++ {
+		excess := len(fl.encodeValue(tf)) - limit
+		if excess <= 0 {
+			break
+		}
+		length -= excess
+		tf = zap.String(f.Key, log.Truncate(content, length, truncateConcat))
+	}
+
+	return tf
+}
+
+// encodeValue returns the JSON encoded value of f.
+func (fl fieldLimiter) encodeValue(f zapcore.Field) string {
+	f.Key = ""
+	buf, err := fl.scratch.EncodeEntry(zapcore.Entry{}, []zapcore.Field{f})
+	if err != nil {
+		return ""
+	}
+	defer buf.Free()
+
+	// strip `{"":` and `}` + line ending
+	b := buf.Bytes()
+	end := len(b) - 1
+	for end > 0 && b[end] != '}' {
+		end--
+	}
+	if end < 4 {
+		return ""
+	}
+
+	return string(b[4:end])
+}
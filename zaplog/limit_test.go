@@ -0,0 +1,134 @@
+package zaplog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFieldLimiterLimit(t *testing.T) {
+	long := strings.Repeat("a", 100)
+
+	tests := []struct {
+		name       string
+		fieldLimit int
+		entryLimit int
+		fields     []zapcore.Field
+		// the expected encoded values by key, the missing keys are expected to be dropped
+		want map[string]string
+	}{
+		{
+			name:   "no limits",
+			fields: []zapcore.Field{zap.String("a", long)},
+			want:   map[string]string{"a": `"` + long + `"`},
+		},
+		{
+			name:       "fitting fields",
+			fieldLimit: 10,
+			fields:     []zapcore.Field{zap.String("a", "short"), zap.Int("b", 12345)},
+			want:       map[string]string{"a": `"short"`, "b": "12345"},
+		},
+		{
+			name:       "field limit",
+			fieldLimit: 10,
+			fields:     []zapcore.Field{zap.String("a", long), zap.Int("b", 1)},
+			want:       map[string]string{"a": `"aaa...aa"`, "b": "1", TruncatedKey: `["a"]`},
+		},
+		{
+			name:       "field limit with escaping",
+			fieldLimit: 10,
+			fields:     []zapcore.Field{zap.String("a", strings.Repeat(`"`, 20))},
+			// the escaped quotes don't fit
+			want: map[string]string{"a": `"..."`, TruncatedKey: `["a"]`},
+		},
+		{
+			name:       "field limit of non-string values",
+			fieldLimit: 12,
+			fields:     []zapcore.Field{zap.Ints("a", []int{1, 2, 3, 4, 5, 6, 7, 8}), zap.Error(errors.New(long))},
+			want:       map[string]string{"a": `"[1,2...,8]"`, "error": `"aaaa...aaa"`, TruncatedKey: `["a","error"]`},
+		},
+		{
+			// budget: 57 - 10 (used) - 22 (truncated key overhead) - 3 = 22, "a" uses 10,
+			// listing "b" in the truncated keys uses 4, the remaining 8 is too small for "b" and its overhead
+			name:       "entry limit dropping",
+			entryLimit: 57,
+			fields:     []zapcore.Field{zap.String("a", "aaa"), zap.String("b", long)},
+			want:       map[string]string{"a": `"aaa"`, TruncatedKey: `["b"]`},
+		},
+		{
+			// budget: 80 - 10 - 22 - 3 = 45, "a" uses 10, listing "b" uses 4, leaving 31 - 5 overhead for the value
+			name:       "entry limit truncating",
+			entryLimit: 80,
+			fields:     []zapcore.Field{zap.String("a", "aaa"), zap.String("b", long)},
+			want:       map[string]string{"a": `"aaa"`, "b": `"` + strings.Repeat("a", 11) + "..." + strings.Repeat("a", 10) + `"`, TruncatedKey: `["b"]`},
+		},
+		{
+			name:       "no room for the truncated keys",
+			entryLimit: 30,
+			fields:     []zapcore.Field{zap.String("b", long)},
+			want:       map[string]string{},
+		},
+		{
+			name:       "namespaces are kept",
+			fieldLimit: 5,
+			fields:     []zapcore.Field{zap.Namespace("ns"), zap.String("a", "b")},
+			want:       map[string]string{"ns": "{}", "a": `"b"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fl := newFieldLimiter(fieldsEncoderConfig(zap.NewProductionEncoderConfig()), test.fieldLimit, test.entryLimit)
+			limited := fl.limit(test.fields, 10)
+
+			got := make(map[string]string, len(limited))
+			for _, f := range limited {
+				got[f.Key] = fl.encodeValue(f)
+			}
+
+			if len(got) != len(test.want) {
+				t.Errorf("expected fields %v, got %v", test.want, got)
+			}
+			for k, v := range test.want {
+				if got[k] != v {
+					t.Errorf("expected %v to be %v, got %v", k, v, got[k])
+				}
+			}
+			if test.fieldLimit > 0 {
+				for k, v := range got {
+					if k != TruncatedKey && len(v) > test.fieldLimit {
+						t.Errorf("%v exceeds the field limit: %v", k, v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncoderEntrySizeLimit(t *testing.T) {
+	for _, limit := range []int{80, 100, 150, 200, 400} {
+		enc := newTestEncoder(t, []string{"correlation_id"}, EntrySizeLimit(limit))
+
+		buf, err := enc.EncodeEntry(testEntry(zapcore.InfoLevel, "message"), []zapcore.Field{
+			zap.String("correlation_id", "abc"),
+			zap.String("a", strings.Repeat("a", 60)),
+			zap.String("b", strings.Repeat(`"b"`, 40)),
+			zap.Int("c", 42),
+			zap.Strings("d", []string{strings.Repeat("d", 50), "e"}),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.Len() > limit {
+			t.Errorf("entry of %v bytes exceeds the limit %v: %v", buf.Len(), limit, buf.String())
+		}
+		// with the smaller limits even the truncated keys are dropped
+		if limit >= 150 && !strings.Contains(buf.String(), TruncatedKey) {
+			t.Errorf("missing %v with limit %v: %v", TruncatedKey, limit, buf.String())
+		}
+	}
+}
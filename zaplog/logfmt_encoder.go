@@ -0,0 +1,145 @@
+package zaplog
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/proemergotech/log/v3"
+)
+
+const LogfmtEncoderType = "dliver-logfmt"
+
+type logfmtEncoder struct {
+	*zapcore.MapObjectEncoder
+	specialKeys map[string]struct{}
+	options     EncoderOptions
+	limiter     fieldLimiter
+}
+
+var logfmtKeyReplacer = strings.NewReplacer(" ", "_", "=", "_", "\"", "_", "\n", "_", "\r", "_", "\t", "_")
+
+// NewLogfmtEncoder create a new zapcore.Encoder writing logfmt lines:
+//
+//	ts=<time> level=<level> [logger=<logger name>] msg=<message> <special keys> <fields>
+//
+// The time and level formats, the special keys, the truncation and the stack traces are handled the same way as by NewEncoder,
+// the nested objects and arrays are flattened with dotted keys (eg. request.header.0=value).
+func NewLogfmtEncoder(specialKeys []string, options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+	return func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		eo, err := newEncoderOptions(LogfmtEncoderType, options, optStackTraceDepth, optTimeFormat, optUTC, optLevelNames)
+		if err != nil {
+			return nil, err
+		}
+		if err := eo.checkLineSections(LogfmtEncoderType); err != nil {
+			return nil, err
+		}
+
+		return &logfmtEncoder{
+			MapObjectEncoder: zapcore.NewMapObjectEncoder(),
+			specialKeys:      keySet(specialKeys),
+			options:          eo,
+			limiter:          newFieldLimiter(fieldsEncoderConfig(cfg), eo.fieldSizeLimit, eo.entrySizeLimit),
+		}, nil
+	}
+}
+
+func (le *logfmtEncoder) Clone() zapcore.Encoder {
+	// copy the previously added fields, see devEncoder.Clone
+	enc := zapcore.NewMapObjectEncoder()
+	for k, v := range le.MapObjectEncoder.Fields {
+		enc.Fields[k] = v
+	}
+
+	return &logfmtEncoder{
+		MapObjectEncoder: enc,
+		specialKeys:      le.specialKeys,
+		options:          le.options,
+		limiter:          le.limiter,
+	}
+}
+
+func (le *logfmtEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf := bufferPool.Get()
+
+	buf.AppendString("ts=")
+	le.options.appendTime(buf, entry.Time)
+	buf.AppendString(" level=")
+	buf.AppendString(le.options.levelName(entry.Level))
+	if entry.LoggerName != "" {
+		appendLogfmt(buf, "logger", entry.LoggerName)
+	}
+
+	message := entry.Message
+	if le.options.messageTruncateLimit > 0 {
+		message = log.Truncate(message, le.options.messageTruncateLimit, truncateConcat)
+	}
+	appendLogfmt(buf, "msg", message)
+
+	fb := getFieldsBuffer()
+	defer fb.free()
+
+	special, fields := fb.specialFields(fields, le.specialKeys)
+	for _, field := range special {
+		appendLogfmt(buf, field.Key, fieldString(field))
+	}
+
+	flat := flattenMap(nil, "", le.MapObjectEncoder.Fields)
+	fields = le.options.addStackTrace(fb, entry, fields)
+	fields = le.limiter.limit(fields, buf.Len())
+	flat = flattenFields(flat, "", fields)
+	for _, f := range flat {
+		appendLogfmt(buf, f.key, f.value)
+	}
+
+	buf.AppendString("\n")
+
+	return buf, nil
+}
+
+func appendLogfmt(buf *buffer.Buffer, key string, value interface{}) {
+	buf.AppendString(" ")
+	if key == "" {
+		key = "_"
+	}
+	buf.AppendString(logfmtKeyReplacer.Replace(key))
+	buf.AppendString("=")
+
+	switch v := value.(type) {
+	case nil:
+	case string:
+		if needsQuote(v) {
+			buf.AppendString(strconv.Quote(v))
+		} else {
+			buf.AppendString(v)
+		}
+	case bool:
+		buf.AppendBool(v)
+	case int64:
+		buf.AppendInt(v)
+	case uint64:
+		buf.AppendUint(v)
+	case float64:
+		buf.AppendFloat(v, 64)
+	case json.Number:
+		buf.AppendString(v.String())
+	}
+}
+
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
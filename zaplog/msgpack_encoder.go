@@ -0,0 +1,491 @@
+package zaplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/proemergotech/log/v3"
+	"github.com/proemergotech/log/v3/internal/msgpack"
+)
+
+const MsgpackEncoderType = "dliver-msgpack"
+
+type msgpackEncoder struct {
+	*msgpackValues
+	specialKeys map[string]struct{}
+	options     EncoderOptions
+	limiter     fieldLimiter
+}
+
+// msgpackValues encodes the zap fields in MessagePack format, as map key-value pairs or as array elements.
+// The encoded values are not prefixed by a map or array header, because their count is known only after adding all of them.
+type msgpackValues struct {
+	buf        []byte
+	count      int
+	namespaces []msgpackNamespace
+}
+
+type msgpackNamespace struct {
+	key   string
+	buf   []byte
+	count int
+}
+
+// NewMsgpackEncoder create a new zapcore.Encoder writing every log entry as a MessagePack map:
+//
+//	{"ts": <timestamp>, "level": <level>, ["logger": <logger name>,] "msg": <message>, "special": {<key>: <value>, ...}, "fields": {...}}
+//
+// The entries are not delimited, MessagePack values are self-delimiting. They can be decoded by dliverlog.NewMsgpackScanner.
+// The level names, the special keys, the truncation and the stack traces are handled the same way as by NewEncoder,
+// the size limits are applied to the JSON size of the fields. Durations are encoded as nanoseconds,
+// times as timestamp extensions and reflected values by their JSON representation.
+func NewMsgpackEncoder(specialKeys []string, options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+	return func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		eo, err := newEncoderOptions(MsgpackEncoderType, options, optStackTraceDepth, optLevelNames)
+		if err != nil {
+			return nil, err
+		}
+
+		return &msgpackEncoder{
+			msgpackValues: &msgpackValues{},
+			specialKeys:   keySet(specialKeys),
+			options:       eo,
+			limiter:       newFieldLimiter(fieldsEncoderConfig(cfg), eo.fieldSizeLimit, eo.entrySizeLimit),
+		}, nil
+	}
+}
+
+func (me *msgpackEncoder) Clone() zapcore.Encoder {
+	return &msgpackEncoder{
+		msgpackValues: me.msgpackValues.clone(),
+		specialKeys:   me.specialKeys,
+		options:       me.options,
+		limiter:       me.limiter,
+	}
+}
+
+func (me *msgpackEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	keys := 5
+	if entry.LoggerName != "" {
+		keys++
+	}
+
+	b := make([]byte, 0, 256)
+	b = msgpack.AppendMapHeader(b, keys)
+	b = msgpack.AppendString(b, "ts")
+	b = msgpack.AppendTime(b, entry.Time)
+	b = msgpack.AppendString(b, "level")
+	b = msgpack.AppendString(b, me.options.levelName(entry.Level))
+	if entry.LoggerName != "" {
+		b = msgpack.AppendString(b, "logger")
+		b = msgpack.AppendString(b, entry.LoggerName)
+	}
+
+	message := entry.Message
+	if me.options.messageTruncateLimit > 0 {
+		message = log.Truncate(message, me.options.messageTruncateLimit, truncateConcat)
+	}
+	b = msgpack.AppendString(b, "msg")
+	b = msgpack.AppendString(b, message)
+
+	fb := getFieldsBuffer()
+	defer fb.free()
+
+	special, fields := fb.specialFields(fields, me.specialKeys)
+	b = msgpack.AppendString(b, "special")
+	b = msgpack.AppendMapHeader(b, len(special))
+	for _, field := range special {
+		b = msgpack.AppendString(b, field.Key)
+		b = msgpack.AppendString(b, fieldString(field))
+	}
+
+	fields = me.options.addStackTrace(fb, entry, fields)
+	fields = me.limiter.limit(fields, len(b))
+
+	values := me.msgpackValues.clone()
+	for _, f := range fields {
+		values.addField(f)
+	}
+	b = msgpack.AppendString(b, "fields")
+	b = values.appendMap(b)
+
+	buf := bufferPool.Get()
+	_, _ = buf.Write(b)
+
+	return buf, nil
+}
+
+func (mv *msgpackValues) clone() *msgpackValues {
+	c := &msgpackValues{
+		buf:        append([]byte(nil), mv.buf...),
+		count:      mv.count,
+		namespaces: make([]msgpackNamespace, len(mv.namespaces)),
+	}
+	for i, ns := range mv.namespaces {
+		c.namespaces[i] = msgpackNamespace{key: ns.key, buf: append([]byte(nil), ns.buf...), count: ns.count}
+	}
+
+	return c
+}
+
+// addField adds f, the partially encoded value is replaced by the panic message if the Stringer or marshaler implementation panics.
+func (mv *msgpackValues) addField(f zapcore.Field) {
+	bufLen, count := len(mv.buf), mv.count
+	defer func() {
+		if r := recover(); r != nil {
+			mv.buf, mv.count = mv.buf[:bufLen], count
+			mv.AddString(f.Key, fmt.Sprintf("!PANIC(%v)", r))
+		}
+	}()
+
+	f.AddTo(mv)
+}
+
+// appendMap closes the open namespaces and appends the values to b as a map.
+func (mv *msgpackValues) appendMap(b []byte) []byte {
+	mv.closeNamespaces()
+	b = msgpack.AppendMapHeader(b, mv.count)
+
+	return append(b, mv.buf...)
+}
+
+func (mv *msgpackValues) closeNamespaces() {
+	for i := len(mv.namespaces) - 1; i >= 0; i-- {
+		ns := mv.namespaces[i]
+		inner := mv.buf
+		count := mv.count
+
+		mv.buf = msgpack.AppendString(ns.buf, ns.key)
+		mv.buf = msgpack.AppendMapHeader(mv.buf, count)
+		mv.buf = append(mv.buf, inner...)
+		mv.count = ns.count + 1
+	}
+	mv.namespaces = nil
+}
+
+func (mv *msgpackValues) key(key string) {
+	mv.buf = msgpack.AppendString(mv.buf, key)
+}
+
+func (mv *msgpackValues) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	mv.key(key)
+	return mv.AppendArray(marshaler)
+}
+
+func (mv *msgpackValues) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	mv.key(key)
+	return mv.AppendObject(marshaler)
+}
+
+func (mv *msgpackValues) AddBinary(key string, value []byte) {
+	mv.key(key)
+	mv.buf = msgpack.AppendBytes(mv.buf, value)
+	mv.count++
+}
+
+func (mv *msgpackValues) AddByteString(key string, value []byte) {
+	mv.key(key)
+	mv.AppendByteString(value)
+}
+
+func (mv *msgpackValues) AddBool(key string, value bool) {
+	mv.key(key)
+	mv.AppendBool(value)
+}
+
+func (mv *msgpackValues) AddComplex128(key string, value complex128) {
+	mv.key(key)
+	mv.AppendComplex128(value)
+}
+
+func (mv *msgpackValues) AddComplex64(key string, value complex64) {
+	mv.key(key)
+	mv.AppendComplex64(value)
+}
+
+func (mv *msgpackValues) AddDuration(key string, value time.Duration) {
+	mv.key(key)
+	mv.AppendDuration(value)
+}
+
+func (mv *msgpackValues) AddFloat64(key string, value float64) {
+	mv.key(key)
+	mv.AppendFloat64(value)
+}
+
+func (mv *msgpackValues) AddFloat32(key string, value float32) {
+	mv.key(key)
+	mv.AppendFloat32(value)
+}
+
+func (mv *msgpackValues) AddInt(key string, value int) {
+	mv.key(key)
+	mv.AppendInt64(int64(value))
+}
+
+func (mv *msgpackValues) AddInt64(key string, value int64) {
+	mv.key(key)
+	mv.AppendInt64(value)
+}
+
+func (mv *msgpackValues) AddInt32(key string, value int32) {
+	mv.key(key)
+	mv.AppendInt64(int64(value))
+}
+
+func (mv *msgpackValues) AddInt16(key string, value int16) {
+	mv.key(key)
+	mv.AppendInt64(int64(value))
+}
+
+func (mv *msgpackValues) AddInt8(key string, value int8) {
+	mv.key(key)
+	mv.AppendInt64(int64(value))
+}
+
+func (mv *msgpackValues) AddString(key, value string) {
+	mv.key(key)
+	mv.AppendString(value)
+}
+
+func (mv *msgpackValues) AddTime(key string, value time.Time) {
+	mv.key(key)
+	mv.AppendTime(value)
+}
+
+func (mv *msgpackValues) AddUint(key string, value uint) {
+	mv.key(key)
+	mv.AppendUint64(uint64(value))
+}
+
+func (mv *msgpackValues) AddUint64(key string, value uint64) {
+	mv.key(key)
+	mv.AppendUint64(value)
+}
+
+func (mv *msgpackValues) AddUint32(key string, value uint32) {
+	mv.key(key)
+	mv.AppendUint64(uint64(value))
+}
+
+func (mv *msgpackValues) AddUint16(key string, value uint16) {
+	mv.key(key)
+	mv.AppendUint64(uint64(value))
+}
+
+func (mv *msgpackValues) AddUint8(key string, value uint8) {
+	mv.key(key)
+	mv.AppendUint64(uint64(value))
+}
+
+func (mv *msgpackValues) AddUintptr(key string, value uintptr) {
+	mv.key(key)
+	mv.AppendUint64(uint64(value))
+}
+
+func (mv *msgpackValues) AddReflected(key string, value interface{}) error {
+	// the key is added only if the value can be encoded
+	v, err := reflectedValue(value)
+	if err != nil {
+		return err
+	}
+
+	mv.key(key)
+	mv.buf = appendMsgpackValue(mv.buf, v)
+	mv.count++
+
+	return nil
+}
+
+func (mv *msgpackValues) OpenNamespace(key string) {
+	mv.namespaces = append(mv.namespaces, msgpackNamespace{key: key, buf: mv.buf, count: mv.count})
+	mv.buf = nil
+	mv.count = 0
+}
+
+func (mv *msgpackValues) AppendArray(marshaler zapcore.ArrayMarshaler) error {
+	arr := &msgpackValues{}
+	err := marshaler.MarshalLogArray(arr)
+
+	mv.buf = msgpack.AppendArrayHeader(mv.buf, arr.count)
+	mv.buf = append(mv.buf, arr.buf...)
+	mv.count++
+
+	return err
+}
+
+func (mv *msgpackValues) AppendObject(marshaler zapcore.ObjectMarshaler) error {
+	obj := &msgpackValues{}
+	err := marshaler.MarshalLogObject(obj)
+
+	mv.buf = obj.appendMap(mv.buf)
+	mv.count++
+
+	return err
+}
+
+func (mv *msgpackValues) AppendReflected(value interface{}) error {
+	v, err := reflectedValue(value)
+	if err != nil {
+		// the element is needed to keep the count of the array
+		v = nil
+	}
+
+	mv.buf = appendMsgpackValue(mv.buf, v)
+	mv.count++
+
+	return err
+}
+
+func (mv *msgpackValues) AppendBool(value bool) {
+	mv.buf = msgpack.AppendBool(mv.buf, value)
+	mv.count++
+}
+
+func (mv *msgpackValues) AppendByteString(value []byte) {
+	mv.AppendString(string(value))
+}
+
+func (mv *msgpackValues) AppendComplex128(value complex128) {
+	mv.AppendString(strings.Trim(strconv.FormatComplex(value, 'g', -1, 128), "()"))
+}
+
+func (mv *msgpackValues) AppendComplex64(value complex64) {
+	mv.AppendString(strings.Trim(strconv.FormatComplex(complex128(value), 'g', -1, 64), "()"))
+}
+
+func (mv *msgpackValues) AppendDuration(value time.Duration) {
+	mv.AppendInt64(int64(value))
+}
+
+func (mv *msgpackValues) AppendFloat64(value float64) {
+	mv.buf = msgpack.AppendFloat64(mv.buf, value)
+	mv.count++
+}
+
+func (mv *msgpackValues) AppendFloat32(value float32) {
+	mv.buf = msgpack.AppendFloat32(mv.buf, value)
+	mv.count++
+}
+
+func (mv *msgpackValues) AppendInt(value int) {
+	mv.AppendInt64(int64(value))
+}
+
+func (mv *msgpackValues) AppendInt64(value int64) {
+	mv.buf = msgpack.AppendInt(mv.buf, value)
+	mv.count++
+}
+
+func (mv *msgpackValues) AppendInt32(value int32) {
+	mv.AppendInt64(int64(value))
+}
+
+func (mv *msgpackValues) AppendInt16(value int16) {
+	mv.AppendInt64(int64(value))
+}
+
+func (mv *msgpackValues) AppendInt8(value int8) {
+	mv.AppendInt64(int64(value))
+}
+
+func (mv *msgpackValues) AppendString(value string) {
+	mv.buf = msgpack.AppendString(mv.buf, value)
+	mv.count++
+}
+
+func (mv *msgpackValues) AppendTime(value time.Time) {
+	mv.buf = msgpack.AppendTime(mv.buf, value)
+	mv.count++
+}
+
+func (mv *msgpackValues) AppendUint(value uint) {
+	mv.AppendUint64(uint64(value))
+}
+
+func (mv *msgpackValues) AppendUint64(value uint64) {
+	mv.buf = msgpack.AppendUint(mv.buf, value)
+	mv.count++
+}
+
+func (mv *msgpackValues) AppendUint32(value uint32) {
+	mv.AppendUint64(uint64(value))
+}
+
+func (mv *msgpackValues) AppendUint16(value uint16) {
+	mv.AppendUint64(uint64(value))
+}
+
+func (mv *msgpackValues) AppendUint8(value uint8) {
+	mv.AppendUint64(uint64(value))
+}
+
+func (mv *msgpackValues) AppendUintptr(value uintptr) {
+	mv.AppendUint64(uint64(value))
+}
+
+// reflectedValue converts value to its JSON representation: nil, bool, string, json.Number, []interface{} or map[string]interface{}.
+func reflectedValue(value interface{}) (interface{}, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func appendMsgpackValue(b []byte, v interface{}) []byte {
+	switch t := v.(type) {
+	case bool:
+		return msgpack.AppendBool(b, t)
+	case string:
+		return msgpack.AppendString(b, t)
+	case json.Number:
+		if i, err := strconv.ParseInt(t.String(), 10, 64); err == nil {
+			return msgpack.AppendInt(b, i)
+		}
+		if u, err := strconv.ParseUint(t.String(), 10, 64); err == nil {
+			return msgpack.AppendUint(b, u)
+		}
+		if f, err := t.Float64(); err == nil {
+			return msgpack.AppendFloat64(b, f)
+		}
+		return msgpack.AppendString(b, t.String())
+	case []interface{}:
+		b = msgpack.AppendArrayHeader(b, len(t))
+		for _, e := range t {
+			b = appendMsgpackValue(b, e)
+		}
+		return b
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b = msgpack.AppendMapHeader(b, len(t))
+		for _, k := range keys {
+			b = msgpack.AppendString(b, k)
+			b = appendMsgpackValue(b, t[k])
+		}
+		return b
+	}
+
+	return msgpack.AppendNil(b)
+}
@@ -0,0 +1,7 @@
+//go:build race
+// +build race
+
+package zaplog
+
+// raceEnabled is set when the tests run with the race detector, which makes sync.Pool drop items on purpose.
+const raceEnabled = true
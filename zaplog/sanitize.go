@@ -0,0 +1,177 @@
+package zaplog
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EncodingErrorKey is the field key listing the field values which couldn't be encoded by the dev encoder.
+const EncodingErrorKey = "encoding_error"
+
+const maxSanitizeDepth = 64
+
+// sanitizer converts the values json.Marshal fails on to encodable ones, the problems are collected with their paths.
+type sanitizer struct {
+	// visited contains the maps, slices and pointers of the current path, to detect the cycles
+	visited  map[uintptr]struct{}
+	problems []string
+}
+
+// sanitizeFields returns a copy of fields, in which the unencodable values are replaced by markers:
+// !UNENCODABLE(<value>) for channels, functions, complex numbers, NaN and infinite floats and failing json.Marshalers,
+// !CYCLE(<type>) for cyclic references. The problems are listed in the EncodingErrorKey field.
+func sanitizeFields(fields map[string]interface{}) map[string]interface{} {
+	s := &sanitizer{visited: make(map[uintptr]struct{})}
+
+	sanitized := make(map[string]interface{}, len(fields)+1)
+	for k, v := range fields {
+		sanitized[k] = s.value(k, reflect.ValueOf(v), 0)
+	}
+
+	if len(s.problems) > 0 {
+		sort.Strings(s.problems)
+		sanitized[EncodingErrorKey] = strings.Join(s.problems, "; ")
+	}
+
+	return sanitized
+}
+
+// marshalJSON calls json.Marshal or json.MarshalIndent, recovering the panics of the json.Marshaler implementations.
+func marshalJSON(v interface{}, indent bool) (b []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			b, err = nil, fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if indent {
+		return json.MarshalIndent(v, "", "  ")
+	}
+
+	return json.Marshal(v)
+}
+
+func (s *sanitizer) value(path string, v reflect.Value, depth int) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	if depth > maxSanitizeDepth {
+		return s.problem(path, "maximum depth exceeded", "!UNENCODABLE(max depth)")
+	}
+
+	// the encodable values are kept as they are
+	if v.CanInterface() {
+		b, err := marshalJSON(v.Interface(), false)
+		if err == nil {
+			return json.RawMessage(b)
+		}
+		if _, ok := v.Interface().(json.Marshaler); ok {
+			return s.problem(path, err.Error(), fmt.Sprintf("!UNENCODABLE(%v)", v.Type()))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Ptr {
+			if !s.enter(v.Pointer()) {
+				return s.problem(path, "cycle", fmt.Sprintf("!CYCLE(%v)", v.Type()))
+			}
+			defer s.leave(v.Pointer())
+		}
+		return s.value(path, v.Elem(), depth+1)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		if !s.enter(v.Pointer()) {
+			return s.problem(path, "cycle", fmt.Sprintf("!CYCLE(%v)", v.Type()))
+		}
+		defer s.leave(v.Pointer())
+
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k := fmt.Sprint(iter.Key())
+			m[k] = s.value(joinKey(path, k), iter.Value(), depth+1)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return nil
+			}
+			if v.Len() > 0 {
+				if !s.enter(v.Pointer()) {
+					return s.problem(path, "cycle", fmt.Sprintf("!CYCLE(%v)", v.Type()))
+				}
+				defer s.leave(v.Pointer())
+			}
+		}
+
+		a := make([]interface{}, v.Len())
+		for i := range a {
+			a[i] = s.value(joinKey(path, strconv.Itoa(i)), v.Index(i), depth+1)
+		}
+		return a
+	case reflect.Struct:
+		m := make(map[string]interface{}, v.NumField())
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				// unexported
+				continue
+			}
+
+			name := field.Name
+			if tag := field.Tag.Get("json"); tag != "" {
+				if tag == "-" {
+					continue
+				}
+				if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+					name = tagName
+				}
+			}
+			m[name] = s.value(joinKey(path, name), v.Field(i), depth+1)
+		}
+		return m
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return s.problem(path, "unsupported value "+strconv.FormatFloat(f, 'g', -1, 64), fmt.Sprintf("!UNENCODABLE(%v)", f))
+		}
+		return f
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return s.problem(path, "unsupported type "+v.Type().String(), fmt.Sprintf("!UNENCODABLE(%#v)", v))
+	}
+
+	return s.problem(path, "unsupported type "+v.Type().String(), fmt.Sprintf("!UNENCODABLE(%v)", v.Type()))
+}
+
+func (s *sanitizer) problem(path string, problem string, marker string) string {
+	s.problems = append(s.problems, path+": "+problem)
+
+	return marker
+}
+
+func (s *sanitizer) enter(ptr uintptr) bool {
+	if _, ok := s.visited[ptr]; ok {
+		return false
+	}
+	s.visited[ptr] = struct{}{}
+
+	return true
+}
+
+func (s *sanitizer) leave(ptr uintptr) {
+	delete(s.visited, ptr)
+}
@@ -0,0 +1,224 @@
+package zaplog
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+// StacktraceKey is the field key of the log call site stack trace, see CaptureStack.
+const StacktraceKey = "stacktrace"
+
+const (
+	maxStackDepth = 32
+	// maxErrorDepth limits the walk of the error chains, protecting against cyclic chains.
+	maxErrorDepth = 64
+	// maxErrorNodes limits the number of the errors visited in the trees of joined errors, protecting against cyclic joins.
+	maxErrorNodes = 1024
+	logPackage    = "github.com/proemergotech/log/v3."
+	zaplogPackage = "github.com/proemergotech/log/v3/zaplog."
+)
+
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// stackTrace is the stack of a log call site, formatted the same way as the pkg/errors stack traces.
+type stackTrace errors.StackTrace
+
+func (st stackTrace) String() string {
+	return strings.TrimPrefix(fmt.Sprintf("%+v", errors.StackTrace(st)), "\n")
+}
+
+// captureStack returns the stack of the caller, without the frames of this library's loggers.
+func captureStack() stackTrace {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs)
+	pcs = pcs[:n]
+
+	for len(pcs) > 0 {
+		fn := runtime.FuncForPC(pcs[0] - 1)
+		if fn == nil || !isLogFrame(fn.Name()) {
+			break
+		}
+		pcs = pcs[1:]
+	}
+
+	st := make(stackTrace, len(pcs))
+	for i, pc := range pcs {
+		st[i] = errors.Frame(pc)
+	}
+
+	return st
+}
+
+func isLogFrame(function string) bool {
+	return strings.HasPrefix(function, logPackage) || strings.HasPrefix(function, zaplogPackage)
+}
+
+// hasStackTrace checks whether any error in the err chain, or in the errors joined by them, carries a stack trace.
+func hasStackTrace(err error) bool {
+	return errorTreeHasStackTrace(err, 0)
+}
+
+// unwrapError returns the cause of err, or the errors joined by err (errors.Join, multierr).
+func unwrapError(err error) (cause error, joined []error) {
+	switch e := err.(type) {
+	case interface{ Cause() error }:
+		return e.Cause(), nil
+	case interface{ Unwrap() []error }:
+		return nil, e.Unwrap()
+	case interface{ Errors() []error }:
+		return nil, e.Errors()
+	case interface{ Unwrap() error }:
+		return e.Unwrap(), nil
+	}
+
+	return nil, nil
+}
+
+// errorTreeHasStackTrace checks whether any error in the err chain, or in the errors joined by them, carries a stack trace.
+func errorTreeHasStackTrace(err error, depth int) bool {
+	nodes := 0
+	return treeHasStackTrace(err, depth, &nodes)
+}
+
+func treeHasStackTrace(err error, depth int, nodes *int) bool {
+	for ; err != nil && depth < maxErrorDepth && *nodes < maxErrorNodes; depth++ {
+		*nodes++
+		if _, ok := err.(stackTracer); ok {
+			return true
+		}
+
+		cause, joined := unwrapError(err)
+		for _, e := range joined {
+			if treeHasStackTrace(e, depth+1, nodes) {
+				return true
+			}
+		}
+		err = cause
+	}
+
+	return false
+}
+
+type stackFrame struct {
+	function string
+	file     string
+	line     int
+}
+
+type stackFrames []stackFrame
+
+func (sf stackFrame) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("function", sf.function)
+	enc.AddString("file", sf.file)
+	enc.AddInt("line", sf.line)
+
+	return nil
+}
+
+func (sf stackFrames) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, f := range sf {
+		if err := enc.AppendObject(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// framesOf resolves the frames of st, depth <= 0 means no limit.
+func framesOf(st errors.StackTrace, depth int) stackFrames {
+	if depth > 0 && len(st) > depth {
+		st = st[:depth]
+	}
+
+	frames := make(stackFrames, 0, len(st))
+	for _, f := range st {
+		pc := uintptr(f) - 1
+		fn := runtime.FuncForPC(pc)
+		if fn == nil {
+			frames = append(frames, stackFrame{function: "unknown", file: "unknown"})
+			continue
+		}
+
+		file, line := fn.FileLine(pc)
+		frames = append(frames, stackFrame{function: fn.Name(), file: file, line: line})
+	}
+
+	return frames
+}
+
+// parseStack parses a stack trace formatted by zap (zapcore.Entry.Stack), depth <= 0 means no limit.
+func parseStack(stack string, depth int) stackFrames {
+	lines := strings.Split(strings.TrimSpace(stack), "\n")
+
+	frames := make(stackFrames, 0, len(lines)/2)
+	for i := 0; i+1 < len(lines); i += 2 {
+		if depth > 0 && len(frames) == depth {
+			break
+		}
+
+		frame := stackFrame{function: lines[i], file: strings.TrimSpace(lines[i+1])}
+		if sep := strings.LastIndex(frame.file, ":"); sep >= 0 {
+			if line, err := strconv.Atoi(frame.file[sep+1:]); err == nil {
+				frame.file = frame.file[:sep]
+				frame.line = line
+			}
+		}
+		frames = append(frames, frame)
+	}
+
+	return frames
+}
+
+// errorStackTrace returns the deepest (oldest) stack trace of the err chain.
+// The chain is followed into the first joined error carrying a stack trace.
+func errorStackTrace(err error) errors.StackTrace {
+	var st errors.StackTrace
+	for depth := 0; err != nil && depth < maxErrorDepth; depth++ {
+		if sErr, ok := err.(stackTracer); ok {
+			st = sErr.StackTrace()
+		}
+
+		cause, joined := unwrapError(err)
+		for _, e := range joined {
+			if errorTreeHasStackTrace(e, depth+1) {
+				cause = e
+				break
+			}
+		}
+		err = cause
+	}
+
+	return st
+}
+
+// verboseError returns the message of err followed by its stack trace, formatted as by pkg/errors.
+// The pkg/errors chains format themselves, including the stack traces of the wrapping errors,
+// the other chains (eg. fmt.Errorf with %w, errors.Join) get the deepest stack trace, see errorStackTrace.
+func verboseError(err error) string {
+	type causer interface {
+		Cause() error
+	}
+
+	e := err
+	for depth := 0; e != nil && depth < maxErrorDepth; depth++ {
+		if _, ok := e.(stackTracer); ok {
+			return fmt.Sprintf("%+v", err)
+		}
+
+		cause, ok := e.(causer)
+		if !ok {
+			break
+		}
+		e = cause.Cause()
+	}
+
+	return err.Error() + fmt.Sprintf("%+v", errorStackTrace(err))
+}
@@ -0,0 +1,107 @@
+package zaplog
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type emptyContextMapper struct{}
+
+func (emptyContextMapper) Values(ctx context.Context) map[string]string {
+	return nil
+}
+
+// wrappedErrors returns errors carrying the stack trace of pkgErr in different chains.
+func wrappedErrors(pkgErr error) map[string]error {
+	return map[string]error{
+		"pkg/errors":         pkgErr,
+		"pkg/errors wrapped": errors.Wrap(pkgErr, "ctx"),
+		"fmt wrapped":        fmt.Errorf("ctx: %w", pkgErr),
+		"wrapped twice":      errors.WithMessage(fmt.Errorf("ctx: %w", pkgErr), "outer"),
+		"joined":             stderrors.Join(stderrors.New("plain"), fmt.Errorf("ctx: %w", pkgErr)),
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	pkgErr := errors.New("boom")
+	want := pkgErr.(stackTracer).StackTrace()
+
+	for name, err := range wrappedErrors(pkgErr) {
+		if !hasStackTrace(err) {
+			t.Errorf("%v: expected a stack trace", name)
+		}
+		if st := errorStackTrace(err); len(st) == 0 || st[0] != want[0] {
+			t.Errorf("%v: expected the stack trace of the wrapped error, got %v", name, st)
+		}
+		if v := verboseError(err); !strings.Contains(v, "boom") || !strings.Contains(v, "TestErrorStackTrace") {
+			t.Errorf("%v: expected the message and the stack trace, got %q", name, v)
+		}
+	}
+
+	plain := stderrors.Join(stderrors.New("a"), fmt.Errorf("ctx: %w", stderrors.New("b")))
+	if hasStackTrace(plain) || errorStackTrace(plain) != nil {
+		t.Errorf("unexpected stack trace of %v", plain)
+	}
+}
+
+func TestCaptureStackWithErrorStackTrace(t *testing.T) {
+	for name, err := range wrappedErrors(errors.New("boom")) {
+		core, logs := observer.New(zapcore.ErrorLevel)
+		logger := NewLogger(zap.New(core), emptyContextMapper{}, CaptureStack(true))
+
+		logger.Error(context.Background(), "failed", "error", err)
+		for _, entry := range logs.AllUntimed() {
+			for _, f := range entry.Context {
+				if f.Key == StacktraceKey {
+					t.Errorf("%v: call site stack trace added beside the error stack trace", name)
+				}
+			}
+		}
+	}
+}
+
+func TestEncoderStackTraceOfWrappedErrors(t *testing.T) {
+	gelf := func(options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return NewGELFEncoder("host", options...)
+	}
+	encoders := map[string]func(options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error){
+		"dliver": func(options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+			return NewEncoder(nil, options...)
+		},
+		"logfmt": func(options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+			return NewLogfmtEncoder(nil, options...)
+		},
+		"msgpack": func(options ...EncoderOption) func(zapcore.EncoderConfig) (zapcore.Encoder, error) {
+			return NewMsgpackEncoder(nil, options...)
+		},
+		"ECS":  NewECSEncoder,
+		"GELF": gelf,
+		"GCP":  NewGCPEncoder,
+	}
+
+	for encName, newEncoder := range encoders {
+		enc, err := newEncoder()(zap.NewProductionEncoderConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for errName, err := range wrappedErrors(errors.New("boom")) {
+			buf, encErr := enc.EncodeEntry(testEntry(zapcore.ErrorLevel, "failed"), []zapcore.Field{zap.Error(err)})
+			if encErr != nil {
+				t.Fatal(encErr)
+			}
+			if !strings.Contains(buf.String(), "TestEncoderStackTraceOfWrappedErrors") {
+				t.Errorf("%v %v: missing stack trace: %v", encName, errName, buf.String())
+			}
+			buf.Free()
+		}
+	}
+}
@@ -10,10 +10,19 @@ import (
 	"github.com/proemergotech/log/v3"
 )
 
+type LoggerOption func(lo *LoggerOptions)
+
+type LoggerOptions struct {
+	errorLevels  bool
+	captureStack bool
+}
+
 type logger struct {
 	sugar     *zap.SugaredLogger
+	core      zapcore.Core
 	ctxMapper log.ContextMapper
 	debug     bool
+	options   LoggerOptions
 }
 
 type fields []interface{}
@@ -21,11 +30,34 @@ type fields []interface{}
 // NewLogger creates a new Logger backed by zap.
 // This logger will check the debug level settings of the passed zap.Logger instance
 // and handle some methods differently, based on that.
-func NewLogger(zapLogger *zap.Logger, ctxLogger log.ContextMapper) log.Logger {
+func NewLogger(zapLogger *zap.Logger, ctxLogger log.ContextMapper, options ...LoggerOption) log.Logger {
+	lo := LoggerOptions{}
+
+	for _, option := range options {
+		option(&lo)
+	}
+
 	return &logger{
 		sugar:     zapLogger.Sugar(),
+		core:      zapLogger.Core(),
 		ctxMapper: ctxLogger,
 		debug:     zapLogger.Core().Enabled(zapcore.DebugLevel),
+		options:   lo,
+	}
+}
+
+// ErrorLevels makes Error log entries with an error implementing log.Leveler to be logged on the declared level.
+func ErrorLevels(enabled bool) LoggerOption {
+	return func(lo *LoggerOptions) {
+		lo.errorLevels = enabled
+	}
+}
+
+// CaptureStack adds the stack trace of the log call site to Error and Panic log entries in the StacktraceKey field,
+// if none of the errors in the fields carries a stack trace.
+func CaptureStack(enabled bool) LoggerOption {
+	return func(lo *LoggerOptions) {
+		lo.captureStack = enabled
 	}
 }
 
@@ -34,23 +66,56 @@ func (l *logger) IsDebug(ctx context.Context) bool {
 }
 
 func (l *logger) Debug(ctx context.Context, msg string, keysAndValues ...interface{}) {
-	l.sugar.Debugw(msg, fields(keysAndValues).addAll(l.ctxMapper.Values(ctx)).processFields()...)
+	if f, ok := l.prepare(ctx, zapcore.DebugLevel, keysAndValues); ok {
+		l.sugar.Debugw(msg, f...)
+	}
 }
 
 func (l *logger) Info(ctx context.Context, msg string, keysAndValues ...interface{}) {
-	l.sugar.Infow(msg, fields(keysAndValues).addAll(l.ctxMapper.Values(ctx)).processFields()...)
+	if f, ok := l.prepare(ctx, zapcore.InfoLevel, keysAndValues); ok {
+		l.sugar.Infow(msg, f...)
+	}
 }
 
 func (l *logger) Warn(ctx context.Context, msg string, keysAndValues ...interface{}) {
-	l.sugar.Warnw(msg, fields(keysAndValues).addAll(l.ctxMapper.Values(ctx)).processFields()...)
+	if f, ok := l.prepare(ctx, zapcore.WarnLevel, keysAndValues); ok {
+		l.sugar.Warnw(msg, f...)
+	}
 }
 
 func (l *logger) Error(ctx context.Context, msg string, keysAndValues ...interface{}) {
-	l.sugar.Errorw(msg, fields(keysAndValues).addAll(l.ctxMapper.Values(ctx)).processFields()...)
+	level := zapcore.ErrorLevel
+	if l.options.errorLevels {
+		switch fields(keysAndValues).level(log.ErrorLevel) {
+		case log.DebugLevel:
+			level = zapcore.DebugLevel
+		case log.InfoLevel:
+			level = zapcore.InfoLevel
+		case log.WarnLevel:
+			level = zapcore.WarnLevel
+		}
+	}
+
+	f, ok := l.prepare(ctx, level, keysAndValues)
+	if !ok {
+		return
+	}
+
+	switch level {
+	case zapcore.DebugLevel:
+		l.sugar.Debugw(msg, f...)
+	case zapcore.InfoLevel:
+		l.sugar.Infow(msg, f...)
+	case zapcore.WarnLevel:
+		l.sugar.Warnw(msg, f...)
+	default:
+		l.sugar.Errorw(msg, f...)
+	}
 }
 
 func (l *logger) Panic(ctx context.Context, msg string, keysAndValues ...interface{}) {
-	l.sugar.Panicw(msg, fields(keysAndValues).addAll(l.ctxMapper.Values(ctx)).processFields()...)
+	f, _ := l.prepare(ctx, zapcore.PanicLevel, keysAndValues)
+	l.sugar.Panicw(msg, f...)
 }
 
 func (l *logger) Dump(msg string, v ...interface{}) {
@@ -63,10 +128,36 @@ func (l *logger) Dump(msg string, v ...interface{}) {
 		args = append(args, "arg"+strconv.Itoa(i), arg)
 	}
 
-	l.sugar.Debugw(msg, fields(args).processFields()...)
+	l.sugar.Debugw(msg, fields(log.ResolveLazy(args)).processFields()...)
+}
+
+// prepare returns the fields of the log entry, if the level is enabled. The fields are processed only after the level check,
+// so the log.Lazy values are resolved only for the emitted entries.
+func (l *logger) prepare(ctx context.Context, level zapcore.Level, keysAndValues []interface{}) (fields, bool) {
+	// zap always writes the panic and fatal entries
+	if level < zapcore.DPanicLevel && !l.core.Enabled(level) {
+		return nil, false
+	}
+
+	f := fields(log.ResolveLazy(keysAndValues))
+	if level >= zapcore.ErrorLevel {
+		f = l.withStack(f)
+	}
+
+	return f.addAll(l.ctxMapper.Values(ctx)).processFields(), true
+}
+
+// withStack adds the log call site stack trace to the fields if CaptureStack is enabled.
+func (l *logger) withStack(f fields) fields {
+	if !l.options.captureStack || f.hasStackTrace() {
+		return f
+	}
+
+	return append(f, zap.Stringer(StacktraceKey, captureStack()))
 }
 
 func (f fields) processFields() fields {
+	metadata := false
 	for _, v := range f {
 		errI := v
 		if zField, ok := v.(zapcore.Field); ok && zField.Type == zapcore.ErrorType {
@@ -75,12 +166,49 @@ func (f fields) processFields() fields {
 
 		if err, ok := errI.(error); ok {
 			f = append(f, errorFields(err)...)
+
+			// the metadata fields of the first error only, to avoid duplicated keys
+			if !metadata {
+				f = append(f, log.ErrorMetadata(err)...)
+				metadata = true
+			}
 		}
 	}
 
 	return f
 }
 
+func (f fields) hasStackTrace() bool {
+	for _, v := range f {
+		errI := v
+		if zField, ok := v.(zapcore.Field); ok && zField.Type == zapcore.ErrorType {
+			errI = zField.Interface
+		}
+
+		if err, ok := errI.(error); ok && hasStackTrace(err) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// level returns the level declared by the first error in the fields, see log.Leveler.
+func (f fields) level(defaultLevel int) int {
+	for _, v := range f {
+		errI := v
+		if zField, ok := v.(zapcore.Field); ok && zField.Type == zapcore.ErrorType {
+			errI = zField.Interface
+		}
+
+		if err, ok := errI.(error); ok {
+			return log.LevelOf(err, defaultLevel)
+		}
+	}
+
+	return defaultLevel
+}
+
 func (f fields) addAll(m map[string]string) fields {
 	for k, v := range m {
 		f = append(f, zap.String(k, v))